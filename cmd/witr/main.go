@@ -32,6 +32,8 @@ func main() {
 		warnFlag    = flag.Bool("warnings", false, "show only warnings")
 		noColorFlag = flag.Bool("no-color", false, "disable color")
 		envFlag     = flag.Bool("env", false, "show environment variables")
+		k8sFlag     = flag.Bool("k8s-api", false, "query the Kubernetes API for pod owners")
+		explainFlag = flag.Bool("explain-detection", false, "show every detector's verdict and evidence")
 		helpFlag    = flag.Bool("help", false, "show help")
 		versionFlag = flag.Bool("version", false, "show version")
 	)
@@ -46,6 +48,8 @@ func main() {
 		return
 	}
 
+	detect.KubeAPI = *k8sFlag
+
 	// Resolve target to PID
 	pid, err := resolveTarget(*pidFlag, *portFlag, flag.Args())
 	if err != nil {
@@ -53,6 +57,20 @@ func main() {
 		os.Exit(1)
 	}
 
+	// A port held by docker-proxy is really served by a container
+	var proxy *detect.ProxyHop
+	if *portFlag > 0 {
+		if proxy = detect.ResolveDockerProxy(pid, process.GetCmdline(pid)); proxy != nil {
+			pid = proxy.TargetPID
+		}
+	}
+
+	// PID 1 holding the port means a socket unit waiting to start a service
+	var socket *detect.SocketActivation
+	if *portFlag > 0 && pid == 1 {
+		socket = detect.FindSocketUnit(*portFlag)
+	}
+
 	// Build ancestry chain
 	ancestry, err := process.BuildAncestry(pid)
 	if err != nil || len(ancestry) == 0 {
@@ -75,11 +93,18 @@ func main() {
 		procs[i] = p
 	}
 
-	src := detect.Detect(procs)
-	warnings := detect.Warnings(procs)
+	// Every detector runs once; the primary source and layers share it
+	verdicts := detect.Explain(procs)
+	if *explainFlag {
+		renderExplain(target, verdicts, *jsonFlag, color)
+		return
+	}
+
+	src := detect.Primary(verdicts)
+	warnings := detect.Warnings(procs, src)
 
 	if *jsonFlag {
-		renderJSON(ancestry, src, warnings)
+		renderJSON(ancestry, src, detect.Layers(procs, verdicts), warnings, proxy, socket)
 	} else if *warnFlag {
 		renderWarnings(warnings, color)
 	} else if *treeFlag {
@@ -87,7 +112,7 @@ func main() {
 	} else if *shortFlag {
 		renderShort(ancestry, color)
 	} else {
-		renderStandard(ancestry, src, warnings, color)
+		renderStandard(ancestry, src, detect.Layers(procs, verdicts), warnings, proxy, socket, color)
 	}
 }
 
@@ -103,6 +128,9 @@ Options:
   --warnings     Show only warnings
   --no-color     Disable colorized output
   --env          Show environment variables
+  --k8s-api      Query the Kubernetes API for pod owners
+  --explain-detection
+                 Show every detector's verdict and the evidence behind it
   --help         Show this help
   --version      Show version`)
 }
@@ -170,10 +198,16 @@ func resolvePort(port int) (int, error) {
 	}
 
 	if len(sockets) == 0 {
+		// Published container ports may have no host-side listener
+		if pid := detect.PublishedPortPID(port); pid > 0 {
+			return pid, nil
+		}
 		return 0, fmt.Errorf("no process listening on port %d", port)
 	}
 
-	// Find PID by scanning /proc/*/fd for matching inodes
+	// Find PID by scanning /proc/*/fd for matching inodes. With socket
+	// activation PID 1 holds the socket too; prefer the service using it.
+	holder := 0
 	entries, _ := os.ReadDir("/proc")
 	for _, e := range entries {
 		pid, err := strconv.Atoi(e.Name())
@@ -187,11 +221,18 @@ func resolvePort(port int) (int, error) {
 			if strings.HasPrefix(link, "socket:[") {
 				inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
 				if _, ok := sockets[inode]; ok {
-					return pid, nil
+					if pid != 1 {
+						return pid, nil
+					}
+					holder = pid
+					break
 				}
 			}
 		}
 	}
+	if holder != 0 {
+		return holder, nil
+	}
 	return 0, fmt.Errorf("socket found on port %d but process not detected (try sudo)", port)
 }
 
@@ -226,15 +267,62 @@ func renderEnv(p process.Process, asJSON bool) {
 	}
 }
 
-func renderJSON(ancestry []process.Process, src detect.Source, warnings []string) {
-	out, _ := json.MarshalIndent(map[string]any{
+func renderJSON(ancestry []process.Process, src detect.Source, layers []detect.Source, warnings []string, proxy *detect.ProxyHop, socket *detect.SocketActivation) {
+	result := map[string]any{
 		"ancestry": ancestry,
 		"source":   src,
+		"layers":   layers,
 		"warnings": warnings,
-	}, "", "  ")
+	}
+	if proxy != nil {
+		result["proxy"] = proxy
+	}
+	if socket != nil {
+		result["socket"] = socket
+	}
+	out, _ := json.MarshalIndent(result, "", "  ")
 	fmt.Println(string(out))
 }
 
+func renderExplain(p process.Process, verdicts []detect.Verdict, asJSON, color bool) {
+	if asJSON {
+		out, _ := json.MarshalIndent(map[string]any{
+			"pid":      p.PID,
+			"verdicts": verdicts,
+		}, "", "  ")
+		fmt.Println(string(out))
+		return
+	}
+	fmt.Printf("Detection for %s (pid %d), in priority order:\n\n", p.Command, p.PID)
+	width := 0
+	for _, v := range verdicts {
+		width = max(width, len(v.Detector))
+	}
+	for _, v := range verdicts {
+		fmt.Printf("  %4d  %-*s  ", v.Priority, width, v.Detector)
+		if v.Source == nil {
+			if color {
+				fmt.Printf("%sno match%s\n", dim, reset)
+			} else {
+				fmt.Println("no match")
+			}
+			continue
+		}
+		fmt.Printf("%s %s (confidence %.2f)", v.Source.Type, v.Source.Name, v.Source.Confidence)
+		if v.Selected {
+			if color {
+				fmt.Printf("  %s← selected%s", green, reset)
+			} else {
+				fmt.Print("  ← selected")
+			}
+		}
+		fmt.Println()
+		for _, e := range v.Source.Evidence {
+			fmt.Printf("%*s- %s\n", 10+width, "", e)
+		}
+	}
+}
+
 func renderWarnings(warnings []string, color bool) {
 	if len(warnings) == 0 {
 		fmt.Println("No warnings.")
@@ -280,7 +368,7 @@ func renderShort(ancestry []process.Process, color bool) {
 	}
 }
 
-func renderStandard(ancestry []process.Process, src detect.Source, warnings []string, color bool) {
+func renderStandard(ancestry []process.Process, src detect.Source, layers []detect.Source, warnings []string, proxy *detect.ProxyHop, socket *detect.SocketActivation, color bool) {
 	p := ancestry[len(ancestry)-1]
 
 	label := func(s string) string {
@@ -304,7 +392,19 @@ func renderStandard(ancestry []process.Process, src detect.Source, warnings []st
 	fmt.Printf("%s: %s\n", label("Started"), formatTime(p.StartedAt))
 
 	// Ancestry chain
-	fmt.Printf("\n%s:\n  ", label("Why It Exists"))
+	fmt.Printf("\n%s:\n", label("Why It Exists"))
+	if proxy != nil {
+		fmt.Printf("  docker-proxy (pid %d) forwards :%d to %s:%d\n",
+			proxy.PID, proxy.HostPort, proxy.ContainerIP, proxy.ContainerPort)
+	}
+	if socket != nil {
+		mode := "on first connection"
+		if socket.Accept {
+			mode = "per connection"
+		}
+		fmt.Printf("  %s (%s) starts %s %s\n", socket.Unit, socket.Listen, socket.Service, mode)
+	}
+	fmt.Print("  ")
 	for i, a := range ancestry {
 		fmt.Printf("%s (pid %d)", a.Command, a.PID)
 		if i < len(ancestry)-1 {
@@ -316,6 +416,9 @@ func renderStandard(ancestry []process.Process, src detect.Source, warnings []st
 		}
 	}
 	fmt.Println()
+	if chain := src.Details["runtime_chain"]; chain != "" {
+		fmt.Printf("  via %s\n", chain)
+	}
 
 	// Source
 	fmt.Printf("\n%s: %s", label("Source"), src.Name)
@@ -323,6 +426,239 @@ func renderStandard(ancestry []process.Process, src detect.Source, warnings []st
 		fmt.Printf(" (%s)", src.Type)
 	}
 	fmt.Println()
+	if src.Description != "" {
+		fmt.Printf("  %s\n", src.Description)
+	}
+
+	// Layers, when more than the primary source is involved
+	if len(layers) > 2 {
+		fmt.Printf("\n%s:\n", label("Layers"))
+		width := 0
+		for _, l := range layers {
+			width = max(width, len(l.Type))
+		}
+		for _, l := range layers {
+			fmt.Printf("  %-*s  %s", width, l.Type, l.Name)
+			if l.PID > 0 {
+				fmt.Printf(" (pid %d)", l.PID)
+			}
+			fmt.Println()
+		}
+	}
+
+	// Unit
+	if src.Type == detect.SourceSystemd && src.Details["unit_file"] != "" {
+		details := cloneDetails(src.Details)
+		if hint, ok := restartHints[details["restart"]]; ok {
+			details["restart"] += " (" + hint + ")"
+		}
+		if sched := details["timer_schedule"]; sched != "" {
+			details["timer"] += " (" + sched + ")"
+		}
+		renderDetails(label("Unit"), details, [][2]string{
+			{"File", "unit_file"},
+			{"Drop-Ins", "drop_ins"},
+			{"Type", "service_type"},
+			{"ExecStart", "exec_start"},
+			{"Restart", "restart"},
+			{"Timer", "timer"},
+			{"Socket", "socket"},
+			{"Listen", "socket_listen"},
+			{"User", "user"},
+			{"Working Dir", "working_dir"},
+			{"Env File", "environment_file"},
+			{"Wanted By", "wanted_by"},
+			{"Enabled", "enabled"},
+		})
+	}
+
+	// logind session, for a session scope or a shell or detached process
+	// inside one
+	if src.Details["session_id"] != "" {
+		renderDetails(label("Login Session"), src.Details, [][2]string{
+			{"ID", "session_id"},
+			{"User", "session_user"},
+			{"State", "session_state"},
+			{"Service", "session_service"},
+			{"Type", "session_type"},
+			{"TTY", "tty"},
+			{"Seat", "seat"},
+			{"Remote Host", "remote_host"},
+			{"Remote User", "remote_user"},
+			{"Started", "session_start"},
+			{"Lingering", "linger"},
+		})
+	}
+
+	// inetd service
+	if src.Type == detect.SourceSupervisor && src.Details["inetd_service"] != "" {
+		renderDetails(label("Service"), src.Details, [][2]string{
+			{"Name", "inetd_service"},
+			{"Config", "config_file"},
+			{"Port", "port"},
+			{"Socket", "socket_type"},
+			{"Protocol", "protocol"},
+			{"Server", "server"},
+			{"Args", "server_args"},
+			{"User", "user"},
+		})
+	}
+
+	// Crontab
+	if src.Type == detect.SourceCron {
+		renderDetails(label("Crontab"), src.Details, [][2]string{
+			{"File", "crontab_file"},
+			{"Line", "crontab_line"},
+			{"Schedule", "schedule"},
+			{"Command", "cron_command"},
+			{"User", "cron_user"},
+			{"Script", "cron_script"},
+			{"Next Run", "next_run"},
+		})
+	}
+
+	// CI job
+	if src.Type == detect.SourceCI {
+		renderDetails(label("CI Job"), src.Details, [][2]string{
+			{"System", "ci_system"},
+			{"Project", "ci_project"},
+			{"Workflow", "ci_workflow"},
+			{"Pipeline", "ci_pipeline"},
+			{"Job", "ci_job"},
+			{"Job ID", "ci_job_id"},
+			{"Run ID", "ci_run_id"},
+			{"Build", "ci_run_number"},
+			{"Runner", "ci_runner"},
+			{"URL", "ci_url"},
+		})
+	}
+
+	// Editor or IDE
+	if src.Type == detect.SourceIDE {
+		renderDetails(label("IDE"), src.Details, [][2]string{
+			{"Editor", "ide"},
+			{"Workspace", "workspace"},
+			{"Terminal", "terminal"},
+			{"Commit", "server_commit"},
+		})
+	}
+
+	// SSH login
+	if src.Type == detect.SourceShell {
+		renderDetails(label("Login"), src.Details, [][2]string{
+			{"SSH User", "ssh_user"},
+			{"From", "ssh_client"},
+			{"Port", "ssh_client_port"},
+			{"TTY", "tty"},
+			{"Login Time", "login_time"},
+			{"Sudo User", "sudo_user"},
+			{"Hops", "privilege_hops"},
+		})
+	}
+
+	// Session (tmux, screen, zellij)
+	if src.Type == detect.SourceMultiplexer {
+		renderDetails(label("Session"), src.Details, [][2]string{
+			{"Multiplexer", "multiplexer"},
+			{"Backend", "backend"},
+			{"Session", "session"},
+			{"Window", "window"},
+			{"Pane", "pane"},
+			{"Socket", "socket"},
+		})
+	}
+
+	// Program (supervisord)
+	if src.Type == detect.SourceSupervisor && src.Details["supervisor_program"] != "" {
+		details := cloneDetails(src.Details)
+		if hint, ok := autorestartHints[details["autorestart"]]; ok {
+			details["autorestart"] += " (" + hint + ")"
+		}
+		renderDetails(label("Program"), details, [][2]string{
+			{"Name", "supervisor_program"},
+			{"Group", "supervisor_group"},
+			{"Config", "config_file"},
+			{"Section", "config_section"},
+			{"Command", "command"},
+			{"Autorestart", "autorestart"},
+			{"Directory", "directory"},
+			{"User", "user"},
+		})
+	}
+
+	// App (pm2)
+	if src.Type == detect.SourceSupervisor && src.Details["pm2_app"] != "" {
+		renderDetails(label("PM2 App"), src.Details, [][2]string{
+			{"Name", "pm2_app"},
+			{"ID", "pm2_id"},
+			{"Script", "script"},
+			{"Mode", "exec_mode"},
+			{"Restarts", "restarts"},
+			{"Watch", "watch"},
+			{"Home", "pm2_home"},
+			{"Startup", "startup"},
+			{"Resurrect", "resurrect"},
+		})
+	}
+
+	// Sandbox
+	if src.Type == detect.SourceSnap || src.Type == detect.SourceFlatpak {
+		renderDetails(label("Sandbox"), src.Details, [][2]string{
+			{"Snap", "snap_name"},
+			{"App", "snap_app"},
+			{"Flatpak", "flatpak_app"},
+			{"Revision", "revision"},
+			{"Version", "version"},
+			{"Branch", "branch"},
+			{"Runtime", "flatpak_runtime"},
+			{"Confinement", "confinement"},
+			{"Filesystems", "filesystems"},
+			{"Unit", "systemd_unit"},
+		})
+	}
+
+	// Pod / Container
+	if src.Type == detect.SourceKubernetes {
+		details := cloneDetails(src.Details)
+		if id := details["sandbox_id"]; len(id) > 12 {
+			details["sandbox_id"] = id[:12]
+		}
+		renderDetails(label("Pod"), details, [][2]string{
+			{"Name", "pod_name"},
+			{"Namespace", "namespace"},
+			{"UID", "pod_uid"},
+			{"QoS", "qos_class"},
+			{"Container", "container_name"},
+			{"Sandbox", "sandbox_id"},
+			{"Runtime", "runtime"},
+			{"Owners", "owner_chain"},
+			{"Owners Error", "owner_chain_error"},
+			{"Helm", "helm_release"},
+			{"Chart", "helm_chart"},
+		})
+	} else if src.Details["runtime"] != "" {
+		details := cloneDetails(src.Details)
+		if id := details["container_id"]; len(id) > 12 {
+			details["container_id"] = id[:12]
+		}
+		renderDetails(label("Container"), details, [][2]string{
+			{"Name", "container_name"},
+			{"ID", "container_id"},
+			{"Runtime", "runtime"},
+			{"Image", "image"},
+			{"Created", "created"},
+			{"State", "state"},
+			{"Restart", "restart_policy"},
+			{"Restarts", "restart_count"},
+			{"Compose File", "compose_config_files"},
+			{"Pod", "pod_name"},
+			{"Unit", "systemd_unit"},
+			{"Quadlet", "quadlet_source"},
+			{"Class", "machine_class"},
+			{"Leader PID", "leader_pid"},
+			{"Root", "root_directory"},
+		})
+	}
 
 	// Context
 	if p.WorkingDir != "" {
@@ -358,6 +694,53 @@ func renderStandard(ancestry []process.Process, src detect.Source, warnings []st
 	}
 }
 
+// restartHints explains what a systemd Restart= policy means when the
+// process is killed by hand.
+var restartHints = map[string]string{
+	"no":          "not restarted",
+	"always":      "restarted whenever it exits, even if killed",
+	"on-success":  "restarted only after a clean exit",
+	"on-failure":  "restarted on non-zero exit or unclean signal such as SIGKILL",
+	"on-abnormal": "restarted on unclean signal, timeout or watchdog",
+	"on-abort":    "restarted on unclean signal",
+	"on-watchdog": "restarted on watchdog timeout",
+}
+
+// autorestartHints explains supervisord's autorestart= values.
+var autorestartHints = map[string]string{
+	"true":       "restarted whenever it exits, even if killed",
+	"false":      "not restarted",
+	"unexpected": "restarted unless it exits with one of its exitcodes; a kill counts as unexpected",
+}
+
+func cloneDetails(m map[string]string) map[string]string {
+	c := make(map[string]string, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// renderDetails prints a titled block of source details in the given
+// order, skipping keys that are not set.
+func renderDetails(title string, details map[string]string, fields [][2]string) {
+	width := 0
+	for _, f := range fields {
+		if details[f[1]] != "" && len(f[0]) > width {
+			width = len(f[0])
+		}
+	}
+	if width == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for _, f := range fields {
+		if v := details[f[1]]; v != "" {
+			fmt.Printf("  %-*s: %s\n", width, f[0], v)
+		}
+	}
+}
+
 func formatTime(t time.Time) string {
 	dur := time.Since(t)
 	var rel string
@@ -0,0 +1,113 @@
+package detect
+
+import (
+	"os"
+	"strings"
+)
+
+// readCgroup returns the cgroup path systemd manages for pid: the
+// name=systemd hierarchy on cgroup v1, or the unified hierarchy on v2.
+func readCgroup(pid int) string {
+	data, err := os.ReadFile("/proc/" + itoa(pid) + "/cgroup")
+	if err != nil {
+		return ""
+	}
+	return parseCgroup(string(data))
+}
+
+// parseCgroup picks the systemd-relevant path out of /proc/<pid>/cgroup.
+// Lines look like "1:name=systemd:/system.slice/nginx.service" (v1) or
+// "0::/system.slice/nginx.service" (v2). Hybrid setups list both.
+func parseCgroup(data string) string {
+	var unified string
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		switch {
+		case parts[1] == "name=systemd":
+			return parts[2]
+		case parts[0] == "0" && parts[1] == "":
+			unified = parts[2]
+		}
+	}
+	return unified
+}
+
+// systemdUnit describes the unit owning a cgroup path.
+type systemdUnit struct {
+	Unit    string // e.g. nginx.service, docker-<id>.scope
+	Slice   string // innermost slice, e.g. system.slice
+	Manager string // "system" or the user manager unit, e.g. user@1000.service
+	UID     string // owning user for user manager units
+}
+
+var unitSuffixes = []string{".service", ".scope", ".mount", ".socket", ".swap"}
+
+func isUnitName(s string) bool {
+	for _, suffix := range unitSuffixes {
+		if strings.HasSuffix(s, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSystemdUnit walks a cgroup path and returns the innermost unit.
+// Paths below user@UID.service belong to that user's systemd instance:
+//
+//	/system.slice/nginx.service
+//	/user.slice/user-1000.slice/user@1000.service/app.slice/foo.service
+func parseSystemdUnit(path string) *systemdUnit {
+	u := &systemdUnit{Manager: "system"}
+	for _, part := range strings.Split(path, "/") {
+		switch {
+		case strings.HasPrefix(part, "user@") && strings.HasSuffix(part, ".service"):
+			u.Manager = part
+			u.UID = strings.TrimSuffix(strings.TrimPrefix(part, "user@"), ".service")
+			u.Unit = part
+		case strings.HasSuffix(part, ".slice"):
+			u.Slice = part
+		case isUnitName(part):
+			u.Unit = part
+		}
+	}
+	if u.Unit == "" {
+		return nil
+	}
+	if u.Unit == u.Manager {
+		// The user manager itself runs in the system instance
+		u.Manager = "system"
+	}
+	return u
+}
+
+// sessionID returns N for a logind session scope, session-N.scope.
+func sessionID(unit string) string {
+	if id, ok := strings.CutPrefix(unit, "session-"); ok && strings.HasSuffix(id, ".scope") {
+		return strings.TrimSuffix(id, ".scope")
+	}
+	return ""
+}
+
+// CgroupUnit returns the innermost systemd unit owning pid, or "" when
+// the process is not in a unit or only in PID 1's init.scope.
+func CgroupUnit(pid int) string {
+	u := parseSystemdUnit(readCgroup(pid))
+	if u == nil || u.Unit == "init.scope" {
+		return ""
+	}
+	return u.Unit
+}
+
+// inSystemService reports whether p runs in a .service unit of the system
+// manager. Shells and worker masters there are only the unit's ExecStart,
+// a wrapper script or gunicorn, so the unit is the better answer.
+func inSystemService(p Process) bool {
+	if !strings.HasSuffix(p.GetService(), ".service") {
+		return false
+	}
+	u := parseSystemdUnit(readCgroup(p.GetPID()))
+	return u != nil && u.Manager == "system"
+}
@@ -0,0 +1,168 @@
+package detect
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// ciSystem describes how to recognise a CI agent and the job it runs.
+type ciSystem struct {
+	Name   string      // source name, e.g. github-actions
+	Title  string      // for descriptions, e.g. GitHub Actions
+	Comms  []string    // agent process names
+	Args   []string    // agent jar names, for JVM-hosted agents
+	Marker string      // environment variable every job sees
+	Env    [][2]string // detail key, environment variable
+	URL    func(env func(string) string) string
+}
+
+// ciSystems maps each agent's job environment to Source details.
+var ciSystems = []ciSystem{
+	{
+		Name: "github-actions", Title: "GitHub Actions",
+		Comms: []string{"Runner.Worker"}, Marker: "GITHUB_ACTIONS",
+		Env: [][2]string{
+			{"ci_project", "GITHUB_REPOSITORY"},
+			{"ci_workflow", "GITHUB_WORKFLOW"},
+			{"ci_job", "GITHUB_JOB"},
+			{"ci_run_id", "GITHUB_RUN_ID"},
+			{"ci_run_number", "GITHUB_RUN_NUMBER"},
+			{"ci_runner", "RUNNER_NAME"},
+		},
+		URL: func(env func(string) string) string {
+			if env("GITHUB_SERVER_URL") == "" || env("GITHUB_REPOSITORY") == "" || env("GITHUB_RUN_ID") == "" {
+				return ""
+			}
+			return env("GITHUB_SERVER_URL") + "/" + env("GITHUB_REPOSITORY") + "/actions/runs/" + env("GITHUB_RUN_ID")
+		},
+	},
+	{
+		Name: "gitlab-runner", Title: "GitLab CI",
+		Comms: []string{"gitlab-runner", "gitlab-ci-multi"}, Marker: "GITLAB_CI",
+		Env: [][2]string{
+			{"ci_project", "CI_PROJECT_PATH"},
+			{"ci_pipeline", "CI_PIPELINE_ID"},
+			{"ci_job", "CI_JOB_NAME"},
+			{"ci_job_id", "CI_JOB_ID"},
+			{"ci_runner", "CI_RUNNER_DESCRIPTION"},
+		},
+		URL: func(env func(string) string) string { return env("CI_JOB_URL") },
+	},
+	{
+		Name: "jenkins", Title: "Jenkins",
+		Args: []string{"agent.jar", "remoting.jar", "slave.jar", "jenkins.war"}, Marker: "JENKINS_URL",
+		Env: [][2]string{
+			{"ci_job", "JOB_NAME"},
+			{"ci_run_number", "BUILD_NUMBER"},
+			{"ci_runner", "NODE_NAME"},
+		},
+		URL: func(env func(string) string) string { return env("BUILD_URL") },
+	},
+	{
+		Name: "buildkite", Title: "Buildkite",
+		Comms: []string{"buildkite-agent"}, Marker: "BUILDKITE",
+		Env: [][2]string{
+			{"ci_project", "BUILDKITE_PIPELINE_SLUG"},
+			{"ci_job", "BUILDKITE_LABEL"},
+			{"ci_job_id", "BUILDKITE_JOB_ID"},
+			{"ci_run_number", "BUILDKITE_BUILD_NUMBER"},
+			{"ci_runner", "BUILDKITE_AGENT_NAME"},
+		},
+		URL: func(env func(string) string) string { return env("BUILDKITE_BUILD_URL") },
+	},
+}
+
+// isAgent reports whether p is the CI system's agent process.
+func (c *ciSystem) isAgent(p Process) bool {
+	for _, comm := range c.Comms {
+		if p.GetCommand() == comm {
+			return true
+		}
+	}
+	args := strings.Fields(p.GetCmdline())
+	for _, arg := range args {
+		for _, a := range c.Args {
+			if filepath.Base(arg) == a {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// detectCI finds the CI job that spawned the target: a runner agent in
+// the ancestry, or, for strays the runner no longer parents, the job
+// environment the target inherited.
+func detectCI(ancestry []Process) *Source {
+	if len(ancestry) == 0 {
+		return nil
+	}
+	for _, c := range ciSystems {
+		agent := -1
+		for i := len(ancestry) - 1; i >= 0; i-- {
+			if c.isAgent(ancestry[i]) {
+				agent = i
+				break
+			}
+		}
+		// Jobs see the marker; the agent itself usually does not
+		env := func(key string) string {
+			for i := len(ancestry) - 1; i > agent; i-- {
+				if v := envValue(ancestry[i], key); v != "" {
+					return v
+				}
+			}
+			return ""
+		}
+		if agent == -1 && env(c.Marker) == "" {
+			continue
+		}
+
+		src := &Source{Type: SourceCI, Name: c.Name, Confidence: 0.85, Details: map[string]string{"ci_system": c.Title}}
+		if agent >= 0 {
+			p := ancestry[agent]
+			src.PID = p.GetPID()
+			src.Evidence = append(src.Evidence, ancestorEvidence(p, "is the "+c.Title+" agent ("+p.GetCommand()+")"))
+		} else {
+			src.Confidence = 0.7
+			src.Evidence = append(src.Evidence, "environment sets "+c.Marker+"="+env(c.Marker))
+		}
+		for _, kv := range c.Env {
+			if v := env(kv[1]); v != "" {
+				src.Details[kv[0]] = v
+				src.Evidence = append(src.Evidence, "environment sets "+kv[1]+"="+v)
+			}
+		}
+		if url := c.URL(env); url != "" {
+			src.Details["ci_url"] = url
+		}
+
+		d := src.Details
+		desc := "Started by " + c.Title
+		if job := d["ci_job"]; job != "" {
+			desc += " job " + job
+		}
+		if wf := d["ci_workflow"]; wf != "" && c.Name == "github-actions" {
+			desc += " in workflow " + wf
+		}
+		var ids []string
+		if p := d["ci_project"]; p != "" {
+			ids = append(ids, p)
+		}
+		for _, id := range [][2]string{{"ci_run_id", "run"}, {"ci_job_id", "job"}, {"ci_run_number", "build"}} {
+			if v := d[id[0]]; v != "" {
+				ids = append(ids, id[1]+" "+v)
+				break
+			}
+		}
+		if len(ids) > 0 {
+			desc += " (" + strings.Join(ids, ", ") + ")"
+		}
+		if agent == -1 {
+			desc += "; the agent is no longer its ancestor"
+		}
+		src.Description = desc
+		return src
+	}
+	return nil
+}
@@ -0,0 +1,347 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// containerInfo is the metadata witr reports for a container, whichever
+// runtime or backend it came from.
+type containerInfo struct {
+	Runtime       string
+	ID            string
+	Name          string
+	Image         string
+	Created       string
+	State         string
+	RestartPolicy string
+	RestartCount  int
+	PID           int
+	Labels        map[string]string
+	IPs           []string // container addresses on its networks
+	Ports         []int    // host ports published to the container
+}
+
+// details flattens the container metadata into Source.Details.
+func (c *containerInfo) details() map[string]string {
+	d := map[string]string{"runtime": c.Runtime, "container_id": c.ID}
+	for k, v := range map[string]string{
+		"container_name": c.Name,
+		"image":          c.Image,
+		"created":        c.Created,
+		"state":          c.State,
+		"restart_policy": c.RestartPolicy,
+	} {
+		if v != "" {
+			d[k] = v
+		}
+	}
+	if c.RestartPolicy != "" || c.RestartCount > 0 {
+		d["restart_count"] = strconv.Itoa(c.RestartCount)
+	}
+	return d
+}
+
+// sourceName is the best human label for the container.
+func (c *containerInfo) sourceName() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return shortID(c.ID)
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func isHexID(s string) bool {
+	if len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !((c >= '0' && c <= '9') || (c >= 'a' && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseContainerID extracts the runtime and container ID from any line of
+// /proc/<pid>/cgroup. Handles the cgroupfs and systemd cgroup drivers:
+//
+//	/docker/<id>
+//	/system.slice/docker-<id>.scope
+func parseContainerID(data string) (runtime, id string) {
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		segs := strings.Split(parts[2], "/")
+		for i, seg := range segs {
+			switch {
+			case seg == "docker" && i+1 < len(segs) && isHexID(segs[i+1]):
+				return "docker", segs[i+1]
+			case strings.HasPrefix(seg, "docker-") && strings.HasSuffix(seg, ".scope"):
+				if id := strings.TrimSuffix(strings.TrimPrefix(seg, "docker-"), ".scope"); isHexID(id) {
+					return "docker", id
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// inspectContainer looks up metadata for a container ID, falling back to
+// just the ID when the runtime's state is unreadable.
+func inspectContainer(runtime, id string) *containerInfo {
+	if runtime == "docker" {
+		for _, backend := range dockerBackends {
+			if info := backend(id); info != nil {
+				return info
+			}
+		}
+	}
+	return &containerInfo{Runtime: runtime, ID: id}
+}
+
+// Labels docker compose sets on the containers it creates.
+const (
+	composeProjectLabel    = "com.docker.compose.project"
+	composeServiceLabel    = "com.docker.compose.service"
+	composeWorkingDirLabel = "com.docker.compose.project.working_dir"
+	composeConfigLabel     = "com.docker.compose.project.config_files"
+)
+
+// composeSource explains a container created by docker compose.
+func composeSource(info *containerInfo) *Source {
+	project, service := info.Labels[composeProjectLabel], info.Labels[composeServiceLabel]
+	if project == "" || service == "" {
+		return nil
+	}
+	details := info.details()
+	details["compose_project"] = project
+	details["compose_service"] = service
+	desc := "Started by docker compose project " + project + ", service " + service
+	if dir := info.Labels[composeWorkingDirLabel]; dir != "" {
+		details["compose_working_dir"] = dir
+		desc += ", from directory " + dir
+	}
+	if files := info.Labels[composeConfigLabel]; files != "" {
+		details["compose_config_files"] = files
+	}
+	return &Source{
+		Type:        SourceCompose,
+		Name:        project + "/" + service,
+		Confidence:  0.95,
+		Description: desc,
+		Details:     details,
+		Evidence:    []string{"container carries label " + composeProjectLabel + "=" + project},
+	}
+}
+
+// PublishedPortPID returns the main PID of the container that publishes
+// port on the host, or 0. Used when no host process holds the socket,
+// e.g. with Docker's userland proxy disabled.
+func PublishedPortPID(port int) int {
+	info := dockerFind(func(c *containerInfo) bool {
+		for _, p := range c.Ports {
+			if p == port {
+				return true
+			}
+		}
+		return false
+	})
+	if info == nil {
+		return 0
+	}
+	return info.PID
+}
+
+// ProxyHop describes a docker-proxy forwarding a host port into a container.
+type ProxyHop struct {
+	PID           int
+	HostPort      int
+	ContainerIP   string
+	ContainerPort int
+	TargetPID     int // container process that receives the traffic
+}
+
+// parseDockerProxy reads the forwarding addresses from a docker-proxy
+// command line, in either "-flag value" or "-flag=value" form:
+//
+//	docker-proxy -proto tcp -host-ip 0.0.0.0 -host-port 8080 -container-ip 172.17.0.2 -container-port 80 -use-listen-fd
+//
+// Only the address flags are read; others, such as the boolean
+// -use-listen-fd, must not consume the next argument.
+func parseDockerProxy(cmdline string) *ProxyHop {
+	args := strings.Fields(cmdline)
+	if len(args) == 0 || filepath.Base(args[0]) != "docker-proxy" {
+		return nil
+	}
+	hop := &ProxyHop{}
+	for i := 1; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			continue
+		}
+		key, val, ok := strings.Cut(strings.TrimLeft(args[i], "-"), "=")
+		if key != "host-port" && key != "container-ip" && key != "container-port" {
+			continue
+		}
+		if !ok && i+1 < len(args) {
+			i++
+			val = args[i]
+		}
+		switch key {
+		case "host-port":
+			hop.HostPort, _ = strconv.Atoi(val)
+		case "container-ip":
+			hop.ContainerIP = val
+		case "container-port":
+			hop.ContainerPort, _ = strconv.Atoi(val)
+		}
+	}
+	return hop
+}
+
+// ResolveDockerProxy maps a docker-proxy process to the container it
+// forwards to, using Docker's state or else the network namespace that owns
+// the container IP. Returns nil if cmdline is not a docker-proxy.
+func ResolveDockerProxy(pid int, cmdline string) *ProxyHop {
+	hop := parseDockerProxy(cmdline)
+	if hop == nil || hop.ContainerIP == "" {
+		return nil
+	}
+	hop.PID = pid
+
+	info := dockerFind(func(c *containerInfo) bool {
+		for _, ip := range c.IPs {
+			if ip == hop.ContainerIP {
+				return true
+			}
+		}
+		return false
+	})
+	if info != nil {
+		hop.TargetPID = info.PID
+	} else {
+		hop.TargetPID = netnsListener(hop.ContainerIP, hop.ContainerPort)
+	}
+	if hop.TargetPID == 0 {
+		return nil
+	}
+	return hop
+}
+
+// Container detection via cgroup, falling back to the shim's command line
+func detectContainer(ancestry []Process) *Source {
+	shim := findShim(ancestry)
+	src := cgroupContainer(ancestry)
+	if shim != nil && (src == nil || src.Details == nil) {
+		// No ID in the cgroup path; the shim knows which container it runs
+		if s := shim.source(); s != nil {
+			if src != nil {
+				s.Evidence, s.PID = src.Evidence, src.PID
+			} else {
+				// The container starts with the shim's child
+				for i, p := range ancestry[:len(ancestry)-1] {
+					if p.GetPID() == shim.PID {
+						s.PID = ancestry[i+1].GetPID()
+					}
+				}
+			}
+			src = s
+		}
+	}
+	if src != nil && shim != nil && len(ancestry) > 0 {
+		shim.annotate(src, ancestry[len(ancestry)-1])
+	}
+	return src
+}
+
+func cgroupContainer(ancestry []Process) *Source {
+	for _, p := range ancestry {
+		data, err := os.ReadFile("/proc/" + itoa(p.GetPID()) + "/cgroup")
+		if err != nil {
+			continue
+		}
+		s := string(data)
+		var src *Source
+		var match string
+		if pod := parseKubePod(s); pod != nil {
+			src, match = kubernetesSource(pod), "kubepods pod "+pod.UID
+		} else if c := parsePodman(s); c != nil {
+			src, match = c.source(ancestry), "libpod container "+shortID(c.ID)
+		} else if runtime, id := parseContainerID(s); id != "" {
+			info := inspectContainer(runtime, id)
+			if src = composeSource(info); src == nil {
+				src = &Source{Type: SourceContainer, Name: info.sourceName(), Confidence: 0.95, Details: info.details()}
+			}
+			match = runtime + " container " + shortID(id)
+		} else if name := parseLXC(s); name != "" {
+			src, match = lxcSource(name, ancestry), "lxc container "+name
+		} else if name := parseMachine(s); name != "" {
+			src, match = machineSource(name), "machine-"+name+".scope"
+		} else if strings.Contains(s, "docker") || strings.Contains(s, "containerd") || strings.Contains(s, "kubepods") {
+			src, match = &Source{Type: SourceContainer, Name: "container", Confidence: 0.9}, "a container runtime name"
+		} else {
+			continue
+		}
+		src.PID = p.GetPID()
+		src.Evidence = append([]string{"cgroup path of pid " + itoa(p.GetPID()) + " contains " + match}, src.Evidence...)
+		return src
+	}
+	return nil
+}
+
+// CgroupContainer names the container pid runs in from its cgroup path,
+// as runtime/name (e.g. "docker/web", "kubernetes/default/api-7d9f",
+// "podman/3f2a1b9c0d4e"), or "" when pid is not containerised. Pods are
+// checked first, since their containers also carry runtime scope names.
+// Only local state is read, never an API.
+func CgroupContainer(pid int) string {
+	data, err := os.ReadFile("/proc/" + itoa(pid) + "/cgroup")
+	if err != nil {
+		return ""
+	}
+	s := string(data)
+	if pod := parseKubePod(s); pod != nil {
+		pod.resolve()
+		if pod.Name == "" {
+			return "kubernetes/" + pod.UID
+		}
+		if pod.Namespace == "" {
+			return "kubernetes/" + pod.Name
+		}
+		return "kubernetes/" + pod.Namespace + "/" + pod.Name
+	}
+	if c := parsePodman(s); c != nil {
+		return "podman/" + shortID(c.ID)
+	}
+	if runtime, id := parseContainerID(s); id != "" {
+		if info := dockerStateInfo(id); info != nil {
+			return runtime + "/" + info.sourceName()
+		}
+		return runtime + "/" + shortID(id)
+	}
+	if name := parseLXC(s); name != "" {
+		return "lxc/" + name
+	}
+	if name := parseMachine(s); name != "" {
+		return "machine/" + name
+	}
+	switch {
+	case strings.Contains(s, "kubepods"):
+		return "kubernetes"
+	case strings.Contains(s, "docker"):
+		return "docker"
+	case strings.Contains(s, "containerd"):
+		return "containerd"
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package detect
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseDockerProxy(t *testing.T) {
+	tests := []struct {
+		name    string
+		cmdline string
+		want    *ProxyHop
+	}{
+		{
+			name:    "separate values",
+			cmdline: "/usr/bin/docker-proxy -proto tcp -host-ip 0.0.0.0 -host-port 8080 -container-ip 172.17.0.2 -container-port 80",
+			want:    &ProxyHop{HostPort: 8080, ContainerIP: "172.17.0.2", ContainerPort: 80},
+		},
+		{
+			name:    "equals form",
+			cmdline: "docker-proxy -proto=tcp -host-port=8443 --container-ip=172.18.0.5 -container-port=443",
+			want:    &ProxyHop{HostPort: 8443, ContainerIP: "172.18.0.5", ContainerPort: 443},
+		},
+		{
+			name:    "boolean flag before an address flag",
+			cmdline: "docker-proxy -use-listen-fd -container-ip 172.17.0.9 -container-port 6379 -host-port 6379",
+			want:    &ProxyHop{HostPort: 6379, ContainerIP: "172.17.0.9", ContainerPort: 6379},
+		},
+		{
+			name:    "boolean flag last",
+			cmdline: "docker-proxy -host-port 5432 -container-ip 172.17.0.3 -container-port 5432 -use-listen-fd",
+			want:    &ProxyHop{HostPort: 5432, ContainerIP: "172.17.0.3", ContainerPort: 5432},
+		},
+		{
+			name:    "not docker-proxy",
+			cmdline: "/usr/sbin/nginx -g daemon off;",
+			want:    nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDockerProxy(tt.cmdline); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDockerProxy(%q) = %+v, want %+v", tt.cmdline, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,348 @@
+package detect
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Crontab locations: the system crontab and cron.d use a user column,
+// per-user spool files do not.
+var (
+	systemCrontab   = "/etc/crontab"
+	cronDDir        = "/etc/cron.d"
+	userCrontabDirs = []string{"/var/spool/cron/crontabs", "/var/spool/cron"}
+	cronPeriods     = []string{"hourly", "daily", "weekly", "monthly"}
+)
+
+// cronEntry is one job line of a crontab.
+type cronEntry struct {
+	File     string
+	Line     int
+	Schedule string // "30 2 * * *" or "@daily"
+	User     string
+	Command  string // without the stdin part after an unescaped %
+}
+
+// parseCrontab reads the jobs in a crontab. System crontabs carry the user
+// in the sixth column; for spool files it is the file's owner, user.
+func parseCrontab(path string, system bool, user string) []cronEntry {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var entries []cronEntry
+	for n, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		schedLen := 5
+		if strings.HasPrefix(fields[0], "@") {
+			schedLen = 1
+		} else if strings.Contains(fields[0], "=") {
+			continue // environment setting, e.g. MAILTO=ops
+		}
+		if system {
+			schedLen++
+		}
+		if len(fields) <= schedLen {
+			continue
+		}
+		e := cronEntry{File: path, Line: n + 1, User: user}
+		if system {
+			e.User = fields[schedLen-1]
+			e.Schedule = strings.Join(fields[:schedLen-1], " ")
+		} else {
+			e.Schedule = strings.Join(fields[:schedLen], " ")
+		}
+		e.Command = cronCommand(strings.Join(fields[schedLen:], " "))
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// cronCommand drops the text after the first unescaped %, which cron
+// feeds to the job on stdin, and unescapes the \% left in the command.
+func cronCommand(cmd string) string {
+	for i := 0; i < len(cmd); i++ {
+		if cmd[i] == '\\' {
+			i++
+		} else if cmd[i] == '%' {
+			cmd = strings.TrimSpace(cmd[:i])
+			break
+		}
+	}
+	return strings.ReplaceAll(cmd, `\%`, "%")
+}
+
+// cronEntries gathers every job cron knows about.
+func cronEntries() []cronEntry {
+	entries := parseCrontab(systemCrontab, true, "")
+	files, _ := filepath.Glob(cronDDir + "/*")
+	for _, f := range files {
+		// cron skips backups and package-manager leftovers
+		if base := filepath.Base(f); !strings.ContainsAny(base, ".~") {
+			entries = append(entries, parseCrontab(f, true, "")...)
+		}
+	}
+	for _, dir := range userCrontabDirs {
+		users, _ := os.ReadDir(dir)
+		for _, u := range users {
+			if u.Type().IsRegular() {
+				entries = append(entries, parseCrontab(dir+"/"+u.Name(), false, u.Name())...)
+			}
+		}
+	}
+	return entries
+}
+
+// cronShellCommand returns the command cron handed to /bin/sh -c, from
+// the first shell below the cron daemon at ancestry[i].
+func cronShellCommand(ancestry []Process, i int) (string, Process) {
+	for _, p := range ancestry[i+1:] {
+		args := strings.Fields(p.GetCmdline())
+		if len(args) > 2 && shells[filepath.Base(args[0])] && args[1] == "-c" {
+			return strings.Join(args[2:], " "), p
+		}
+	}
+	return "", nil
+}
+
+// matchCronEntry finds the crontab line that started the job: the shell
+// command cron ran, else a line mentioning the target's command.
+func matchCronEntry(entries []cronEntry, command, user string, target Process) *cronEntry {
+	var best *cronEntry
+	bestScore := 0
+	targetCmd := strings.Join(strings.Fields(target.GetCmdline()), " ")
+	targetArgs := strings.Fields(targetCmd)
+	for k := range entries {
+		e := &entries[k]
+		if user != "" && e.User != "" && e.User != user {
+			continue
+		}
+		entryCmd := strings.Join(strings.Fields(e.Command), " ")
+		score := 0
+		switch {
+		case command != "" && entryCmd == command:
+			score = 3
+		case targetCmd != "" && strings.Contains(entryCmd, targetCmd):
+			score = 2
+		case len(targetArgs) > 0 && strings.Contains(entryCmd, targetArgs[len(targetArgs)-1]) && strings.Contains(targetArgs[len(targetArgs)-1], "/"):
+			// e.g. "python3 /opt/jobs/report.py" run via a wrapper
+			score = 1
+		}
+		if score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	return best
+}
+
+// cronPeriodScript finds a run-parts script from /etc/cron.<period> in
+// the ancestry.
+func cronPeriodScript(ancestry []Process) (script, period string) {
+	for _, p := range ancestry {
+		for _, arg := range strings.Fields(p.GetCmdline()) {
+			for _, period := range cronPeriods {
+				dir := "/etc/cron." + period + "/"
+				if strings.HasPrefix(arg, dir) && len(arg) > len(dir) {
+					return arg, period
+				}
+			}
+		}
+	}
+	return "", ""
+}
+
+// cronDetails adds the crontab line that started the job to a cron source.
+func cronDetails(src *Source, ancestry []Process, i int) {
+	command, shell := cronShellCommand(ancestry, i)
+	user := ""
+	if shell != nil {
+		user = shell.GetUser()
+	}
+	details := make(map[string]string)
+	target := ancestry[len(ancestry)-1]
+	desc := "Started by " + src.Name
+
+	if e := matchCronEntry(cronEntries(), command, user, target); e != nil {
+		details["crontab_file"] = e.File
+		details["crontab_line"] = strconv.Itoa(e.Line)
+		details["schedule"] = e.Schedule
+		details["cron_command"] = e.Command
+		if e.User != "" {
+			details["cron_user"] = e.User
+		}
+		desc += " from " + e.File + " line " + itoa(e.Line) + " (" + e.Schedule + ")"
+		if sched, err := parseCronSchedule(e.Schedule); err == nil {
+			if next, ok := sched.next(time.Now()); ok {
+				details["next_run"] = next.Format("2006-01-02 15:04")
+				desc += ", next run " + details["next_run"]
+			}
+		} else if e.Schedule == "@reboot" {
+			details["next_run"] = "at next boot"
+		}
+		src.Evidence = append(src.Evidence, "command matches "+e.File+":"+itoa(e.Line))
+	}
+	if script, period := cronPeriodScript(ancestry); script != "" {
+		details["cron_script"] = script
+		if details["schedule"] == "" {
+			details["schedule"] = "@" + period
+		}
+		desc += ", running " + script + " (" + period + ")"
+		src.Evidence = append(src.Evidence, "ancestry runs "+script)
+	}
+	if len(details) > 0 {
+		src.Description = desc
+		src.Details = details
+	}
+}
+
+// cronSchedule is a parsed five-field cron schedule, as bitsets.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonths = []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronDays   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+func parseCronSchedule(spec string) (*cronSchedule, error) {
+	if macro, ok := cronMacros[spec]; ok {
+		spec = macro
+	}
+	f := strings.Fields(spec)
+	if len(f) != 5 {
+		return nil, fmt.Errorf("cron schedule %q: want 5 fields", spec)
+	}
+	s := &cronSchedule{domStar: f[2] == "*", dowStar: f[4] == "*"}
+	var err error
+	if s.minute, err = parseCronField(f[0], 0, 59, nil); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseCronField(f[1], 0, 23, nil); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseCronField(f[2], 1, 31, nil); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseCronField(f[3], 1, 12, cronMonths); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseCronField(f[4], 0, 7, cronDays); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1 // 7 is Sunday too
+	}
+	return s, nil
+}
+
+// parseCronField parses lists of values, ranges, steps and names:
+// "*/15", "1-5", "mon-fri", "0,30".
+func parseCronField(field string, min, max int, names []string) (uint64, error) {
+	value := func(s string) (int, error) {
+		for i, name := range names {
+			if name != "" && strings.EqualFold(s, name) {
+				return i, nil
+			}
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < min || n > max {
+			return 0, fmt.Errorf("cron field %q: bad value %q", field, s)
+		}
+		return n, nil
+	}
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepStr); err != nil || step < 1 {
+				return 0, fmt.Errorf("cron field %q: bad step", field)
+			}
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			a, b, isRange := strings.Cut(rng, "-")
+			var err error
+			if lo, err = value(a); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if isRange {
+				if hi, err = value(b); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				hi = max
+			}
+		}
+		for n := lo; n <= hi; n += step {
+			bits |= 1 << n
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time after t the schedule fires, looking up to
+// five years ahead to cover 29 February.
+func (s *cronSchedule) next(t time.Time) (time.Time, bool) {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	for d := 0; d < 5*366; d++ {
+		date := day.AddDate(0, 0, d)
+		if s.month&(1<<int(date.Month())) == 0 || !s.dayMatches(date) {
+			continue
+		}
+		for h := 0; h < 24; h++ {
+			if s.hour&(1<<h) == 0 {
+				continue
+			}
+			for m := 0; m < 60; m++ {
+				if s.minute&(1<<m) == 0 {
+					continue
+				}
+				at := time.Date(date.Year(), date.Month(), date.Day(), h, m, 0, 0, t.Location())
+				if !at.Before(t) {
+					return at, true
+				}
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// dayMatches applies cron's rule that a restricted day-of-month and
+// day-of-week match when either does.
+func (s *cronSchedule) dayMatches(date time.Time) bool {
+	domOK := s.dom&(1<<date.Day()) != 0
+	dowOK := s.dow&(1<<int(date.Weekday())) != 0
+	switch {
+	case s.domStar && s.dowStar:
+		return true
+	case s.domStar:
+		return dowOK
+	case s.dowStar:
+		return domOK
+	}
+	return domOK || dowOK
+}
@@ -0,0 +1,197 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCronCommand(t *testing.T) {
+	tests := []struct {
+		cmd, want string
+	}{
+		{"/usr/local/bin/backup --full", "/usr/local/bin/backup --full"},
+		{"mail -s report ops % body text", "mail -s report ops"},
+		{`date +\%Y-\%m-\%d > /tmp/today`, "date +%Y-%m-%d > /tmp/today"},
+		{`date +\%F % stdin`, "date +%F"},
+		{`echo 100\% % line one%line two`, "echo 100%"},
+		{"%", ""},
+	}
+	for _, tt := range tests {
+		if got := cronCommand(tt.cmd); got != tt.want {
+			t.Errorf("cronCommand(%q) = %q, want %q", tt.cmd, got, tt.want)
+		}
+	}
+}
+
+func TestParseCrontab(t *testing.T) {
+	dir := t.TempDir()
+	system := filepath.Join(dir, "crontab")
+	if err := os.WriteFile(system, []byte(`# /etc/crontab
+SHELL=/bin/sh
+MAILTO=ops
+
+30 2 * * * root /usr/local/bin/backup --full
+@daily  www-data  php /var/www/cron.php
+*/5 * * * * root date +\%s % ignored stdin
+0 1 * * *
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	spool := filepath.Join(dir, "alice")
+	if err := os.WriteFile(spool, []byte("15 3 * * 1-5 /home/alice/bin/report.sh > /tmp/r.log 2>&1\n@reboot tmux new -d\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		system bool
+		user   string
+		want   []cronEntry
+	}{
+		{
+			name: "system crontab", path: system, system: true,
+			want: []cronEntry{
+				{File: system, Line: 5, Schedule: "30 2 * * *", User: "root", Command: "/usr/local/bin/backup --full"},
+				{File: system, Line: 6, Schedule: "@daily", User: "www-data", Command: "php /var/www/cron.php"},
+				{File: system, Line: 7, Schedule: "*/5 * * * *", User: "root", Command: "date +%s"},
+			},
+		},
+		{
+			name: "user spool file", path: spool, user: "alice",
+			want: []cronEntry{
+				{File: spool, Line: 1, Schedule: "15 3 * * 1-5", User: "alice", Command: "/home/alice/bin/report.sh > /tmp/r.log 2>&1"},
+				{File: spool, Line: 2, Schedule: "@reboot", User: "alice", Command: "tmux new -d"},
+			},
+		},
+		{
+			name: "missing file", path: filepath.Join(dir, "nope"), system: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCrontab(tt.path, tt.system, tt.user); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCrontab() =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// bits sets the given positions, for comparing parsed fields.
+func bits(ns ...int) uint64 {
+	var b uint64
+	for _, n := range ns {
+		b |= 1 << n
+	}
+	return b
+}
+
+// seq lists from, from+step, ... up to to.
+func seq(from, to, step int) []int {
+	var ns []int
+	for n := from; n <= to; n += step {
+		ns = append(ns, n)
+	}
+	return ns
+}
+
+func TestParseCronSchedule(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    *cronSchedule
+		wantErr bool
+	}{
+		{
+			spec: "0,30 9-17 * * mon-fri",
+			want: &cronSchedule{minute: bits(0, 30), hour: bits(9, 10, 11, 12, 13, 14, 15, 16, 17),
+				dom: bits(seq(1, 31, 1)...), month: bits(seq(1, 12, 1)...), dow: bits(1, 2, 3, 4, 5), domStar: true},
+		},
+		{
+			spec: "*/15 0 1,15 jan-mar,dec 7",
+			want: &cronSchedule{minute: bits(0, 15, 30, 45), hour: bits(0), dom: bits(1, 15),
+				month: bits(1, 2, 3, 12), dow: bits(0, 7)},
+		},
+		{
+			spec: "5-20/5 */6 10/10 * *",
+			want: &cronSchedule{minute: bits(5, 10, 15, 20), hour: bits(0, 6, 12, 18), dom: bits(10, 20, 30),
+				month: bits(seq(1, 12, 1)...), dow: bits(seq(0, 7, 1)...), dowStar: true},
+		},
+		{
+			spec: "@weekly",
+			want: &cronSchedule{minute: bits(0), hour: bits(0), dom: bits(seq(1, 31, 1)...),
+				month: bits(seq(1, 12, 1)...), dow: bits(0), domStar: true},
+		},
+		{spec: "60 * * * *", wantErr: true},
+		{spec: "* * 0 * *", wantErr: true},
+		{spec: "*/0 * * * *", wantErr: true},
+		{spec: "* * * foo *", wantErr: true},
+		{spec: "* * * *", wantErr: true},
+		{spec: "@reboot", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := parseCronSchedule(tt.spec)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseCronSchedule(%q) = %+v, want an error", tt.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCronSchedule(%q) =\n%+v\nwant\n%+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	const friday = "2026-10-16 10:07:30"
+	tests := []struct {
+		spec, from, want string // want "" when it never fires
+	}{
+		{"*/15 * * * *", friday, "2026-10-16 10:15:00"},
+		{"*/15 * * * *", "2026-10-16 10:15:00", "2026-10-16 10:30:00"},
+		{"5-10/2 * * * *", friday, "2026-10-16 10:09:00"},
+		{"30 2 * * *", friday, "2026-10-17 02:30:00"},
+		{"0 9 * * mon-fri", friday, "2026-10-19 09:00:00"},
+		{"0 12 * * 7", friday, "2026-10-18 12:00:00"},
+		{"0 0 13 * *", friday, "2026-11-13 00:00:00"},
+		{"0 0 13 * fri", friday, "2026-10-23 00:00:00"}, // day of month or week
+		{"@hourly", friday, "2026-10-16 11:00:00"},
+		{"@monthly", friday, "2026-11-01 00:00:00"},
+		{"@yearly", friday, "2027-01-01 00:00:00"},
+		{"0 0 29 2 *", friday, "2028-02-29 00:00:00"},
+		{"0 0 29 feb mon", "2028-02-28 12:00:00", "2028-02-29 00:00:00"},
+		{"59 23 31 12 *", "2026-12-31 23:59:00", "2027-12-31 23:59:00"},
+		{"0 0 31 2 *", friday, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec+" after "+tt.from, func(t *testing.T) {
+			sched, err := parseCronSchedule(tt.spec)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, ok := sched.next(at(tt.from))
+			switch {
+			case tt.want == "" && ok:
+				t.Errorf("next = %v, want none", got)
+			case tt.want != "" && (!ok || !got.Equal(at(tt.want))):
+				t.Errorf("next = %v (%v), want %s", got, ok, tt.want)
+			}
+		})
+	}
+}
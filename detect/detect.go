@@ -3,6 +3,7 @@ package detect
 
 import (
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 )
@@ -11,21 +12,34 @@ import (
 type SourceType string
 
 const (
-	SourceContainer  SourceType = "container"
-	SourceSystemd    SourceType = "systemd"
-	SourceLaunchd    SourceType = "launchd"
-	SourceSupervisor SourceType = "supervisor"
-	SourceCron       SourceType = "cron"
-	SourceShell      SourceType = "shell"
-	SourceUnknown    SourceType = "unknown"
+	SourceContainer   SourceType = "container"
+	SourceCompose     SourceType = "compose"
+	SourceKubernetes  SourceType = "kubernetes"
+	SourceSnap        SourceType = "snap"
+	SourceFlatpak     SourceType = "flatpak"
+	SourceSystemd     SourceType = "systemd"
+	SourceLaunchd     SourceType = "launchd"
+	SourceCI          SourceType = "ci"
+	SourceSupervisor  SourceType = "supervisor"
+	SourceCron        SourceType = "cron"
+	SourceIDE         SourceType = "ide"
+	SourceMultiplexer SourceType = "multiplexer"
+	SourceDetached    SourceType = "detached"
+	SourceShell       SourceType = "shell"
+	SourceRuntime     SourceType = "runtime" // container daemons and shims, in layer chains
+	SourceProcess     SourceType = "process" // the target itself, closing a layer chain
+	SourceUnknown     SourceType = "unknown"
 )
 
 // Source describes what started or supervises a process.
 type Source struct {
-	Type       SourceType
-	Name       string
-	Confidence float64
-	Details    map[string]string
+	Type        SourceType
+	Name        string
+	Confidence  float64
+	Description string // one-line narrative, e.g. "Started by docker compose ..."
+	Details     map[string]string
+	PID         int      // process the source was attributed to, if any
+	Evidence    []string // observations that led to the source
 }
 
 // Process is a minimal interface for detection (to avoid circular import).
@@ -41,31 +55,29 @@ type Process interface {
 	GetContainer() string
 	GetService() string
 	GetStartedAt() time.Time
+	GetEnv() []string
 }
 
-// Detect identifies the source that started/supervises the target process.
-// Priority: container > supervisor > cron > shell > systemd/launchd
+// Detect identifies the source that started/supervises the target process,
+// asking each registered detector in priority order.
+// Built-in priority: rules > container > snap/flatpak > CI job > supervisor >
+// cron > editor/IDE > tmux/screen > nohup/setsid > shell > systemd/launchd
 func Detect(ancestry []Process) Source {
-	if src := detectContainer(ancestry); src != nil {
-		return *src
-	}
-	if src := detectSupervisor(ancestry); src != nil {
-		return *src
-	}
-	if src := detectCron(ancestry); src != nil {
-		return *src
-	}
-	if src := detectShell(ancestry); src != nil {
-		return *src
-	}
-	if src := detectInit(ancestry); src != nil {
-		return *src
+	for _, r := range registry {
+		if src := r.detector.Detect(ancestry); src != nil {
+			return *src
+		}
 	}
-	return Source{Type: SourceUnknown, Confidence: 0.2}
+	return unknownSource()
 }
 
-// Warnings returns potential issues with the process.
-func Warnings(ancestry []Process) []string {
+func unknownSource() Source {
+	return Source{Type: SourceUnknown, Confidence: 0.2, Evidence: []string{"no detector matched"}}
+}
+
+// Warnings returns potential issues with the process whose primary
+// source, from Detect or Primary, is primary.
+func Warnings(ancestry []Process, primary Source) []string {
 	if len(ancestry) == 0 {
 		return nil
 	}
@@ -103,10 +115,17 @@ func Warnings(ancestry []Process) []string {
 	}
 
 	// Unknown source
-	if Detect(ancestry).Type == SourceUnknown {
+	if primary.Type == SourceUnknown {
 		w = append(w, "No known supervisor detected")
 	}
 
+	// Logins kept alive after logout
+	w = append(w, sessionWarnings(last)...)
+
+	// Rules files that could not be used
+	rules()
+	w = append(w, rulesErrors...)
+
 	return w
 }
 
@@ -119,21 +138,6 @@ func isPublicBind(addrs []string) bool {
 	return false
 }
 
-// Container detection via cgroup
-func detectContainer(ancestry []Process) *Source {
-	for _, p := range ancestry {
-		data, err := os.ReadFile("/proc/" + itoa(p.GetPID()) + "/cgroup")
-		if err != nil {
-			continue
-		}
-		s := string(data)
-		if strings.Contains(s, "docker") || strings.Contains(s, "containerd") || strings.Contains(s, "kubepods") {
-			return &Source{Type: SourceContainer, Name: "container", Confidence: 0.9}
-		}
-	}
-	return nil
-}
-
 // Known supervisors (not including systemd/init - handled separately)
 var supervisors = map[string]string{
 	"pm2": "pm2", "pm2 god": "pm2", "supervisord": "supervisord",
@@ -141,27 +145,56 @@ var supervisors = map[string]string{
 	"runsv": "runit", "runit": "runit", "openrc": "openrc", "monit": "monit",
 	"circusd": "circus", "circus": "circus", "daemontools": "daemontools",
 	"tini": "tini", "docker-init": "docker-init",
+	"xinetd": "xinetd", "inetd": "inetd",
 }
 
+// unitWorkerMasters are supervisors usually started as a systemd unit's
+// ExecStart; in a system .service the unit explains the process instead.
+var unitWorkerMasters = map[string]bool{"gunicorn": true, "uwsgi": true}
+
 func detectSupervisor(ancestry []Process) *Source {
 	// Reverse: find nearest supervisor to target
 	for i := len(ancestry) - 1; i >= 0; i-- {
-		p := ancestry[i]
-		cmd := strings.ToLower(p.GetCommand())
-		cmdline := strings.ToLower(p.GetCmdline())
-
-		// PM2 special case
-		if strings.Contains(cmd, "pm2") || strings.Contains(cmdline, "pm2") {
-			return &Source{Type: SourceSupervisor, Name: "pm2", Confidence: 0.9}
-		}
-		// Known supervisors
-		if name, ok := supervisors[cmd]; ok {
-			return &Source{Type: SourceSupervisor, Name: name, Confidence: 0.7}
-		}
-		for sup, name := range supervisors {
-			if strings.Contains(cmdline, sup) {
-				return &Source{Type: SourceSupervisor, Name: name, Confidence: 0.7}
+		if src := supervisorAt(ancestry[i]); src != nil {
+			if unitWorkerMasters[src.Name] && inSystemService(ancestry[i]) {
+				continue
 			}
+			switch src.Name {
+			case "supervisord":
+				supervisordDetails(src, ancestry, i)
+			case "pm2":
+				pm2Details(src, ancestry, i)
+			case "xinetd", "inetd":
+				inetdDetails(src, ancestry, i)
+			}
+			return src
+		}
+	}
+	return nil
+}
+
+// supervisorAt reports the supervisor p is, if any.
+func supervisorAt(p Process) *Source {
+	cmd := strings.ToLower(p.GetCommand())
+	cmdline := strings.ToLower(p.GetCmdline())
+	// PM2 special case
+	if strings.Contains(cmd, "pm2") || strings.Contains(cmdline, "pm2") {
+		return &Source{Type: SourceSupervisor, Name: "pm2", Confidence: 0.9, PID: p.GetPID(),
+			Evidence: []string{ancestorEvidence(p, "cmdline contains pm2")}}
+	}
+	// Known supervisors
+	if name, ok := supervisors[cmd]; ok {
+		return &Source{Type: SourceSupervisor, Name: name, Confidence: 0.7, PID: p.GetPID(),
+			Evidence: []string{ancestorEvidence(p, "comm="+p.GetCommand())}}
+	}
+	// Interpreted supervisors show up as "python3 /usr/bin/supervisord",
+	// retitled ones as "gunicorn: master [app]"; match whole program
+	// names so xinetd is never taken for inetd
+	args := strings.Fields(cmdline)
+	for _, arg := range args[:min(len(args), 2)] {
+		if name, ok := supervisors[filepath.Base(strings.TrimSuffix(arg, ":"))]; ok {
+			return &Source{Type: SourceSupervisor, Name: name, Confidence: 0.7, PID: p.GetPID(),
+				Evidence: []string{ancestorEvidence(p, "cmdline runs "+arg)}}
 		}
 	}
 	return nil
@@ -170,8 +203,15 @@ func detectSupervisor(ancestry []Process) *Source {
 func detectCron(ancestry []Process) *Source {
 	for i := len(ancestry) - 1; i >= 0; i-- {
 		cmd := ancestry[i].GetCommand()
-		if cmd == "cron" || cmd == "crond" {
-			return &Source{Type: SourceCron, Name: "cron", Confidence: 0.6}
+		if cmd == "cron" || cmd == "crond" || cmd == "anacron" {
+			name := "cron"
+			if cmd == "anacron" {
+				name = cmd
+			}
+			src := &Source{Type: SourceCron, Name: name, Confidence: 0.6, PID: ancestry[i].GetPID(),
+				Evidence: []string{ancestorEvidence(ancestry[i], "comm="+cmd)}}
+			cronDetails(src, ancestry, i)
+			return src
 		}
 	}
 	return nil
@@ -181,13 +221,57 @@ var shells = map[string]bool{"bash": true, "zsh": true, "sh": true, "fish": true
 
 func detectShell(ancestry []Process) *Source {
 	for i := len(ancestry) - 1; i >= 0; i-- {
-		if shells[ancestry[i].GetCommand()] {
-			return &Source{Type: SourceShell, Name: ancestry[i].GetCommand(), Confidence: 0.5}
+		// a unit's wrapper script is not an interactive shell
+		if cmd := ancestry[i].GetCommand(); shells[cmd] && !inSystemService(ancestry[i]) {
+			src := &Source{Type: SourceShell, Name: cmd, Confidence: 0.5, PID: ancestry[i].GetPID(),
+				Evidence: []string{ancestorEvidence(ancestry[i], "comm="+cmd)}}
+			sshDetails(src, ancestry)
+			addLoginSession(src, ancestry[len(ancestry)-1])
+			return src
 		}
 	}
 	return nil
 }
 
+// ancestorEvidence phrases an observation about p for Source.Evidence,
+// e.g. "ancestor pid 812 comm=supervisord".
+func ancestorEvidence(p Process, observation string) string {
+	return "ancestor pid " + itoa(p.GetPID()) + " " + observation
+}
+
+// envValue returns the value of key in the process environment.
+func envValue(p Process, key string) string {
+	prefix := key + "="
+	for _, e := range p.GetEnv() {
+		if strings.HasPrefix(e, prefix) {
+			return e[len(prefix):]
+		}
+	}
+	return ""
+}
+
+// readExe returns the resolved executable path of pid.
+func readExe(pid int) string {
+	exe, _ := os.Readlink("/proc/" + itoa(pid) + "/exe")
+	return strings.TrimSuffix(exe, " (deleted)")
+}
+
+// lookupUser resolves a user, given by UID or by name as Process.GetUser
+// reports it, to its name and home directory via /etc/passwd.
+func lookupUser(user string) (name, home string) {
+	data, err := os.ReadFile("/etc/passwd")
+	if err != nil {
+		return "", ""
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Split(line, ":")
+		if len(fields) > 5 && (fields[2] == user || fields[0] == user) {
+			return fields[0], fields[5]
+		}
+	}
+	return "", ""
+}
+
 func itoa(n int) string {
 	if n == 0 {
 		return "0"
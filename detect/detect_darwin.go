@@ -21,10 +21,12 @@ func detectInit(ancestry []Process) *Source {
 						Name:       label,
 						Confidence: 0.9,
 						Details:    map[string]string{"domain": domain},
+						Evidence:   []string{"launchctl blame " + strconv.Itoa(target.GetPID()) + " reports " + domain + "/" + label},
 					}
 				}
 			}
-			return &Source{Type: SourceLaunchd, Name: "launchd", Confidence: 0.8}
+			return &Source{Type: SourceLaunchd, Name: "launchd", Confidence: 0.8, PID: 1,
+				Evidence: []string{"pid 1 comm=launchd"}}
 		}
 	}
 	return nil
@@ -61,3 +63,27 @@ func getLaunchdLabel(pid int) (label, domain string) {
 
 	return label, domain
 }
+
+// netnsListener is Linux-only; Docker Desktop runs containers in a VM.
+func netnsListener(ip string, port int) int {
+	return 0
+}
+
+// quadletSource is Linux-only; podman machines run containers in a VM.
+func quadletSource(unit, uid string) string {
+	return ""
+}
+
+// FindSocketUnit is Linux-only; launchd's on-demand sockets are not
+// inspected.
+func FindSocketUnit(port int) *SocketActivation {
+	return nil
+}
+
+// addLoginSession is Linux-only; macOS has no logind sessions.
+func addLoginSession(src *Source, target Process) {}
+
+// sessionWarnings is Linux-only; macOS has no logind sessions.
+func sessionWarnings(target Process) []string {
+	return nil
+}
@@ -2,12 +2,161 @@
 
 package detect
 
-// detectInit checks for systemd as PID 1.
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// detectInit checks for systemd as PID 1 and resolves the owning unit.
 func detectInit(ancestry []Process) *Source {
 	for _, p := range ancestry {
 		if p.GetPID() == 1 && p.GetCommand() == "systemd" {
-			return &Source{Type: SourceSystemd, Name: "systemd", Confidence: 0.8}
+			if src := detectSystemdUnit(ancestry[len(ancestry)-1]); src != nil {
+				return src
+			}
+			return &Source{Type: SourceSystemd, Name: "systemd", Confidence: 0.8, PID: 1,
+				Evidence: []string{"pid 1 comm=systemd"}}
 		}
 	}
 	return nil
 }
+
+// detectSystemdUnit maps the target's cgroup to the unit that owns it.
+func detectSystemdUnit(target Process) *Source {
+	u := parseSystemdUnit(readCgroup(target.GetPID()))
+	if u == nil || u.Unit == "init.scope" {
+		return nil
+	}
+	details := map[string]string{"manager": u.Manager}
+	if u.Slice != "" {
+		details["slice"] = u.Slice
+	}
+	if u.UID != "" {
+		details["uid"] = u.UID
+	}
+	unitDetails(u.Unit, u.Manager, u.UID, details)
+	evidence := "cgroup of pid " + itoa(target.GetPID()) + " is in " + u.Unit
+	if u.Manager != "system" {
+		evidence += " under " + u.Manager
+	}
+	src := &Source{Type: SourceSystemd, Name: u.Unit, Confidence: 0.9, Details: details, Evidence: []string{evidence}}
+	if id := sessionID(u.Unit); id != "" {
+		if sd := sessionDetails(id); sd != nil {
+			for k, v := range sd {
+				details[k] = v
+			}
+			src.Description = sessionDescription(sd)
+			src.Evidence = append(src.Evidence, logindSessionsDir+"/"+id+" lists user "+sd["session_user"])
+		}
+		return src
+	}
+	var triggers []string
+	if timer := details["timer"]; timer != "" {
+		trigger := "Triggered by " + timer
+		if sched := details["timer_schedule"]; sched != "" {
+			trigger += " (" + sched + ")"
+		}
+		triggers = append(triggers, trigger)
+		src.Evidence = append(src.Evidence, details["timer_file"]+" activates "+u.Unit)
+	}
+	if socket := details["socket"]; socket != "" {
+		trigger := "Socket-activated by " + socket
+		if listen := details["socket_listen"]; listen != "" {
+			trigger += " (" + listen + ")"
+		}
+		triggers = append(triggers, trigger)
+		src.Evidence = append(src.Evidence, socket+" activates "+u.Unit)
+	}
+	src.Description = strings.Join(triggers, "; ")
+	return src
+}
+
+// netnsListener finds the process listening on port inside the network
+// namespace that owns ip, such as a container behind docker-proxy.
+// Returns the first process in that namespace if none is listening.
+func netnsListener(ip string, port int) int {
+	hostNS, _ := os.Readlink("/proc/self/ns/net")
+	entries, _ := os.ReadDir("/proc")
+	var pids []int
+	for _, e := range entries {
+		if pid, err := strconv.Atoi(e.Name()); err == nil {
+			pids = append(pids, pid)
+		}
+	}
+	sort.Ints(pids)
+
+	byNS := make(map[string][]int)
+	var target string
+	for _, pid := range pids {
+		ns, err := os.Readlink("/proc/" + itoa(pid) + "/ns/net")
+		if err != nil || ns == hostNS {
+			continue
+		}
+		if _, seen := byNS[ns]; !seen && target == "" && hasLocalAddr(pid, ip) {
+			target = ns
+		}
+		byNS[ns] = append(byNS[ns], pid)
+	}
+	if target == "" {
+		return 0
+	}
+
+	members := byNS[target]
+	inodes := listenInodes(members[0], port)
+	for _, pid := range members {
+		fdPath := "/proc/" + itoa(pid) + "/fd"
+		fds, _ := os.ReadDir(fdPath)
+		for _, fd := range fds {
+			link, _ := os.Readlink(fdPath + "/" + fd.Name())
+			if inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]"); inodes[inode] {
+				return pid
+			}
+		}
+	}
+	return members[0]
+}
+
+// hasLocalAddr reports whether ip is a local address in pid's network
+// namespace, per /proc/<pid>/net/fib_trie:
+//
+//	|-- 172.17.0.2
+//	   /32 host LOCAL
+func hasLocalAddr(pid int, ip string) bool {
+	data, err := os.ReadFile("/proc/" + itoa(pid) + "/net/fib_trie")
+	if err != nil {
+		return false
+	}
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i+1 < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "|-- "+ip && strings.Contains(lines[i+1], "host LOCAL") {
+			return true
+		}
+	}
+	return false
+}
+
+// listenInodes returns the inodes of TCP sockets listening on port in
+// pid's network namespace.
+func listenInodes(pid, port int) map[string]bool {
+	inodes := make(map[string]bool)
+	for _, name := range []string{"tcp", "tcp6"} {
+		data, err := os.ReadFile("/proc/" + itoa(pid) + "/net/" + name)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 10 || fields[3] != "0A" { // 0A = LISTEN
+				continue
+			}
+			if idx := strings.LastIndex(fields[1], ":"); idx != -1 {
+				if p, _ := strconv.ParseInt(fields[1][idx+1:], 16, 32); int(p) == port {
+					inodes[fields[9]] = true
+				}
+			}
+		}
+	}
+	return inodes
+}
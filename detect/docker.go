@@ -0,0 +1,293 @@
+package detect
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dockerRoot is the Docker daemon's data directory.
+var dockerRoot = "/var/lib/docker"
+
+// dockerSocket is the Engine API socket used when dockerRoot is unreadable.
+// A unix:// DOCKER_HOST takes precedence.
+var dockerSocket = "/var/run/docker.sock"
+
+// dockerPidFile holds the daemon's PID; dockerd is not in a container's
+// ancestry, which runs through containerd-shim instead.
+var dockerPidFile = "/var/run/docker.pid"
+
+const dockerAPITimeout = 500 * time.Millisecond
+
+// dockerBackends are tried in order until one knows the container.
+var dockerBackends = []func(id string) *containerInfo{
+	dockerStateInfo,
+	dockerAPIInfo,
+}
+
+// dockerListBackends enumerate containers, in the same order.
+var dockerListBackends = []func() []*containerInfo{
+	dockerStateList,
+	dockerAPIList,
+}
+
+// dockerConfig mirrors the parts of config.v2.json witr reads.
+type dockerConfig struct {
+	ID      string
+	Name    string
+	Created string
+	Config  struct {
+		Image  string
+		Labels map[string]string
+	}
+	State struct {
+		Status     string
+		Running    bool
+		Paused     bool
+		Restarting bool
+		Pid        int
+	}
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string
+		}
+	}
+	RestartCount int
+}
+
+// dockerHostConfig mirrors the parts of hostconfig.json witr reads.
+type dockerHostConfig struct {
+	RestartPolicy struct {
+		Name              string
+		MaximumRetryCount int
+	}
+	PortBindings map[string][]struct {
+		HostIP   string `json:"HostIp"`
+		HostPort string
+	}
+}
+
+// dockerInspect is the GET /containers/<id>/json response: the same
+// document as config.v2.json with the host config inlined.
+type dockerInspect struct {
+	dockerConfig
+	HostConfig dockerHostConfig
+}
+
+// info converts daemon state into containerInfo.
+func (cfg *dockerConfig) info(id string, host *dockerHostConfig) *containerInfo {
+	state := cfg.State.Status
+	if state == "" {
+		state = dockerState(cfg.State.Running, cfg.State.Paused, cfg.State.Restarting)
+	}
+	info := &containerInfo{
+		Runtime:      "docker",
+		ID:           id,
+		Name:         strings.TrimPrefix(cfg.Name, "/"),
+		Image:        cfg.Config.Image,
+		Created:      cfg.Created,
+		State:        state,
+		RestartCount: cfg.RestartCount,
+		PID:          cfg.State.Pid,
+		Labels:       cfg.Config.Labels,
+	}
+	for _, n := range cfg.NetworkSettings.Networks {
+		if n.IPAddress != "" {
+			info.IPs = append(info.IPs, n.IPAddress)
+		}
+	}
+	if host != nil {
+		info.RestartPolicy = host.RestartPolicy.Name
+		if host.RestartPolicy.MaximumRetryCount > 0 {
+			info.RestartPolicy += ":" + itoa(host.RestartPolicy.MaximumRetryCount)
+		}
+		for _, bindings := range host.PortBindings {
+			for _, b := range bindings {
+				if port, err := strconv.Atoi(b.HostPort); err == nil {
+					info.Ports = append(info.Ports, port)
+				}
+			}
+		}
+	}
+	return info
+}
+
+// dockerStateInfo reads container metadata straight from the daemon's
+// on-disk state. Usually requires root.
+func dockerStateInfo(id string) *containerInfo {
+	dir := dockerRoot + "/containers/" + id
+	data, err := os.ReadFile(dir + "/config.v2.json")
+	if err != nil {
+		return nil
+	}
+	var cfg dockerConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil
+	}
+
+	var host *dockerHostConfig
+	if data, err := os.ReadFile(dir + "/hostconfig.json"); err == nil {
+		var hc dockerHostConfig
+		if json.Unmarshal(data, &hc) == nil {
+			host = &hc
+		}
+	}
+	return cfg.info(id, host)
+}
+
+// dockerStateList reads every container in the daemon's on-disk state.
+func dockerStateList() []*containerInfo {
+	matches, _ := filepath.Glob(dockerRoot + "/containers/*/config.v2.json")
+	var list []*containerInfo
+	for _, m := range matches {
+		if info := dockerStateInfo(filepath.Base(filepath.Dir(m))); info != nil {
+			list = append(list, info)
+		}
+	}
+	return list
+}
+
+func dockerState(running, paused, restarting bool) string {
+	switch {
+	case restarting:
+		return "restarting"
+	case paused:
+		return "paused"
+	case running:
+		return "running"
+	}
+	return "exited"
+}
+
+// dockerFind returns the first running container that satisfies match,
+// trying each backend until one has it. Listings may be summaries, so the
+// match is inspected in full before it is returned.
+func dockerFind(match func(*containerInfo) bool) *containerInfo {
+	for _, list := range dockerListBackends {
+		for _, info := range list() {
+			if info.State == "running" && match(info) {
+				if info.PID == 0 {
+					return inspectContainer(info.Runtime, info.ID)
+				}
+				return info
+			}
+		}
+	}
+	return nil
+}
+
+// dockerClient talks to the Docker Engine API over a unix socket.
+type dockerClient struct {
+	http *http.Client
+}
+
+func newDockerClient(socket string, timeout time.Duration) *dockerClient {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		},
+	}
+	return &dockerClient{http: &http.Client{Transport: transport, Timeout: timeout}}
+}
+
+// get decodes the JSON response for an API path into v.
+func (c *dockerClient) get(path string, v any) error {
+	resp, err := c.http.Get("http://docker" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker API %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// inspect returns metadata for a container from GET /containers/<id>/json.
+func (c *dockerClient) inspect(id string) (*containerInfo, error) {
+	var resp dockerInspect
+	if err := c.get("/containers/"+url.PathEscape(id)+"/json", &resp); err != nil {
+		return nil, err
+	}
+	return resp.info(id, &resp.HostConfig), nil
+}
+
+// dockerSummary is an entry of GET /containers/json, enough to pick a
+// container by address or published port without inspecting each one.
+type dockerSummary struct {
+	ID     string `json:"Id"`
+	Names  []string
+	Image  string
+	State  string
+	Labels map[string]string
+	Ports  []struct {
+		PrivatePort int
+		PublicPort  int
+	}
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string
+		}
+	}
+}
+
+// list returns the running containers from GET /containers/json. The
+// summaries carry no PID; callers inspect the container they pick.
+func (c *dockerClient) list() ([]*containerInfo, error) {
+	var summaries []dockerSummary
+	if err := c.get("/containers/json", &summaries); err != nil {
+		return nil, err
+	}
+	var list []*containerInfo
+	for _, s := range summaries {
+		info := &containerInfo{Runtime: "docker", ID: s.ID, Image: s.Image, State: s.State, Labels: s.Labels}
+		if len(s.Names) > 0 {
+			info.Name = strings.TrimPrefix(s.Names[0], "/")
+		}
+		for _, p := range s.Ports {
+			if p.PublicPort != 0 {
+				info.Ports = append(info.Ports, p.PublicPort)
+			}
+		}
+		for _, n := range s.NetworkSettings.Networks {
+			if n.IPAddress != "" {
+				info.IPs = append(info.IPs, n.IPAddress)
+			}
+		}
+		list = append(list, info)
+	}
+	return list, nil
+}
+
+// dockerSocketPath honours DOCKER_HOST when it points at a unix socket.
+func dockerSocketPath() string {
+	if host := os.Getenv("DOCKER_HOST"); strings.HasPrefix(host, "unix://") {
+		return strings.TrimPrefix(host, "unix://")
+	}
+	return dockerSocket
+}
+
+// dockerAPIInfo asks the local daemon about a container.
+func dockerAPIInfo(id string) *containerInfo {
+	info, err := newDockerClient(dockerSocketPath(), dockerAPITimeout).inspect(id)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+// dockerAPIList asks the local daemon for a summary of its running
+// containers.
+func dockerAPIList() []*containerInfo {
+	list, _ := newDockerClient(dockerSocketPath(), dockerAPITimeout).list()
+	return list
+}
@@ -0,0 +1,234 @@
+package detect
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDocker serves handler on a unix socket standing in for the Engine
+// API, and points dockerAPIInfo and dockerAPIList at it.
+func fakeDocker(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(handler)
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	old := dockerSocket
+	dockerSocket = socket
+	t.Cleanup(func() { dockerSocket = old })
+	t.Setenv("DOCKER_HOST", "")
+	return socket
+}
+
+const (
+	webID = "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+	dbID  = "fedcba9876543210fedcba9876543210fedcba9876543210fedcba9876543210"
+)
+
+var dockerAPIFixtures = map[string]string{
+	"/containers/json": `[
+		{
+			"Id": "` + webID + `", "Names": ["/web"], "Image": "nginx:1.27", "State": "running",
+			"Ports": [{"PrivatePort": 80, "PublicPort": 8080, "Type": "tcp"}],
+			"NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}}
+		},
+		{
+			"Id": "` + dbID + `", "Names": ["/db"], "Image": "postgres:16", "State": "running",
+			"Ports": [{"PrivatePort": 5432, "Type": "tcp"}],
+			"NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.3"}}}
+		}
+	]`,
+	"/containers/" + webID + "/json": `{
+		"Id": "` + webID + `",
+		"Name": "/web",
+		"Created": "2026-10-01T09:00:00Z",
+		"Config": {"Image": "nginx:1.27", "Labels": {"com.docker.compose.project": "shop"}},
+		"State": {"Status": "running", "Running": true, "Pid": 4242},
+		"NetworkSettings": {"Networks": {"bridge": {"IPAddress": "172.17.0.2"}}},
+		"RestartCount": 3,
+		"HostConfig": {
+			"RestartPolicy": {"Name": "on-failure", "MaximumRetryCount": 5},
+			"PortBindings": {"80/tcp": [{"HostIp": "", "HostPort": "8080"}]}
+		}
+	}`,
+	"/containers/" + dbID + "/json": `{
+		"Id": "` + dbID + `",
+		"Name": "/db",
+		"Config": {"Image": "postgres:16"},
+		"State": {"Status": "running", "Running": true, "Pid": 4343},
+		"HostConfig": {"RestartPolicy": {"Name": "always"}}
+	}`,
+}
+
+func serveFixtures(fixtures map[string]string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := fixtures[r.URL.Path]
+		if !ok {
+			http.Error(w, `{"message":"No such container"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	})
+}
+
+func TestDockerAPIInfo(t *testing.T) {
+	fakeDocker(t, serveFixtures(dockerAPIFixtures))
+
+	info := dockerAPIInfo(webID)
+	if info == nil {
+		t.Fatal("dockerAPIInfo returned nil")
+	}
+	want := containerInfo{
+		Runtime:       "docker",
+		ID:            webID,
+		Name:          "web",
+		Image:         "nginx:1.27",
+		Created:       "2026-10-01T09:00:00Z",
+		State:         "running",
+		RestartPolicy: "on-failure:5",
+		RestartCount:  3,
+		PID:           4242,
+	}
+	got := *info
+	got.Labels, got.IPs, got.Ports = nil, nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("dockerAPIInfo = %+v, want %+v", got, want)
+	}
+	if info.Labels[composeProjectLabel] != "shop" {
+		t.Errorf("labels = %v, want compose project shop", info.Labels)
+	}
+	if len(info.IPs) != 1 || info.IPs[0] != "172.17.0.2" {
+		t.Errorf("IPs = %v, want [172.17.0.2]", info.IPs)
+	}
+	if len(info.Ports) != 1 || info.Ports[0] != 8080 {
+		t.Errorf("Ports = %v, want [8080]", info.Ports)
+	}
+
+	if info := dockerAPIInfo("nosuchcontainer"); info != nil {
+		t.Errorf("dockerAPIInfo(unknown) = %+v, want nil", info)
+	}
+}
+
+func TestDockerAPIList(t *testing.T) {
+	var inspected []string
+	fixtures := serveFixtures(dockerAPIFixtures)
+	fakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			inspected = append(inspected, r.URL.Path)
+		}
+		fixtures.ServeHTTP(w, r)
+	}))
+
+	list := dockerAPIList()
+	if len(list) != 2 {
+		t.Fatalf("dockerAPIList returned %d containers, want 2", len(list))
+	}
+	if len(inspected) != 0 {
+		t.Errorf("dockerAPIList inspected %v, want summaries only", inspected)
+	}
+	web := list[0]
+	if web.Name != "web" || web.State != "running" || !reflect.DeepEqual(web.Ports, []int{8080}) || !reflect.DeepEqual(web.IPs, []string{"172.17.0.2"}) {
+		t.Errorf("dockerAPIList()[0] = %+v, want web on 8080 at 172.17.0.2", web)
+	}
+	if len(list[1].Ports) != 0 {
+		t.Errorf("unpublished port listed: %v", list[1].Ports)
+	}
+}
+
+func TestDockerFind(t *testing.T) {
+	var inspected []string
+	fixtures := serveFixtures(dockerAPIFixtures)
+	fakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			inspected = append(inspected, r.URL.Path)
+		}
+		fixtures.ServeHTTP(w, r)
+	}))
+
+	// The state backend lists a container, but not the one on 8080
+	root := t.TempDir()
+	old := dockerRoot
+	dockerRoot = root
+	t.Cleanup(func() { dockerRoot = old })
+	otherID := strings.Repeat("ab", 32)
+	if err := os.MkdirAll(root+"/containers/"+otherID, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cfg := `{"ID":"` + otherID + `","Name":"/other","State":{"Running":true,"Pid":99}}`
+	if err := os.WriteFile(root+"/containers/"+otherID+"/config.v2.json", []byte(cfg), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if pid := PublishedPortPID(8080); pid != 4242 {
+		t.Errorf("PublishedPortPID(8080) = %d, want 4242 from the API backend", pid)
+	}
+	if want := []string{"/containers/" + webID + "/json"}; !reflect.DeepEqual(inspected, want) {
+		t.Errorf("inspected %v, want only %v", inspected, want)
+	}
+	if pid := PublishedPortPID(9999); pid != 0 {
+		t.Errorf("PublishedPortPID(9999) = %d, want 0", pid)
+	}
+}
+
+func TestDockerAPIMissingSocket(t *testing.T) {
+	old := dockerSocket
+	dockerSocket = filepath.Join(t.TempDir(), "missing.sock")
+	t.Cleanup(func() { dockerSocket = old })
+	t.Setenv("DOCKER_HOST", "")
+
+	if info := dockerAPIInfo(webID); info != nil {
+		t.Errorf("dockerAPIInfo = %+v, want nil", info)
+	}
+	if list := dockerAPIList(); list != nil {
+		t.Errorf("dockerAPIList = %v, want nil", list)
+	}
+}
+
+func TestDockerAPITimeout(t *testing.T) {
+	release := make(chan struct{})
+	socket := fakeDocker(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer close(release)
+
+	start := time.Now()
+	_, err := newDockerClient(socket, 50*time.Millisecond).inspect(webID)
+	if err == nil {
+		t.Fatal("inspect against a hung daemon succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("inspect took %v, want it to give up after the timeout", elapsed)
+	}
+}
+
+func TestDockerAPIMalformedJSON(t *testing.T) {
+	socket := fakeDocker(t, serveFixtures(map[string]string{
+		"/containers/json":               `[{"Id":`,
+		"/containers/" + webID + "/json": `{"Id": "` + webID + `", "State": "running"`,
+	}))
+
+	c := newDockerClient(socket, dockerAPITimeout)
+	if _, err := c.inspect(webID); err == nil {
+		t.Error("inspect accepted a truncated document")
+	}
+	if _, err := c.list(); err == nil {
+		t.Error("list accepted a truncated document")
+	}
+	if info := dockerAPIInfo(webID); info != nil {
+		t.Errorf("dockerAPIInfo = %+v, want nil", info)
+	}
+}
@@ -0,0 +1,195 @@
+package detect
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// jetbrainsLaunchers maps JetBrains native launcher names to products.
+var jetbrainsLaunchers = map[string]string{
+	"idea": "IntelliJ IDEA", "goland": "GoLand", "pycharm": "PyCharm",
+	"webstorm": "WebStorm", "clion": "CLion", "rider": "Rider",
+	"phpstorm": "PhpStorm", "rubymine": "RubyMine", "rustrover": "RustRover",
+}
+
+// ideAt recognises an editor or IDE process:
+//
+//	~/.vscode-server/bin/<commit>/node ...     VS Code (remote server)
+//	/usr/lib/code-server/lib/node ...          code-server
+//	goland, idea, or java com.intellij.idea.Main
+//	nvim --embed                               Neovim
+func ideAt(p Process) (name string, ok bool) {
+	comm := p.GetCommand()
+	args := strings.Fields(p.GetCmdline())
+	arg0 := ""
+	if len(args) > 0 {
+		arg0 = args[0]
+	}
+	switch {
+	case strings.Contains(arg0, "/.vscode-server") && filepath.Base(arg0) == "node":
+		return "VS Code", true
+	case comm == "code-server" || (comm == "node" && strings.Contains(arg0, "code-server")):
+		return "code-server", true
+	case jetbrainsLaunchers[comm] != "":
+		return jetbrainsLaunchers[comm], true
+	case comm == "java" && strings.Contains(p.GetCmdline(), "com.intellij.idea.Main"):
+		return "JetBrains IDE", true
+	case comm == "nvim" && containsArg(args, "--embed"):
+		return "Neovim", true
+	}
+	return "", false
+}
+
+func containsArg(args []string, arg string) bool {
+	for _, a := range args {
+		if a == arg {
+			return true
+		}
+	}
+	return false
+}
+
+// vscodeCommit returns the build commit in a VS Code server path,
+// ~/.vscode-server/bin/<commit>/node.
+func vscodeCommit(arg0 string) string {
+	if _, rest, ok := strings.Cut(arg0, "/.vscode-server/bin/"); ok {
+		commit, _, _ := strings.Cut(rest, "/")
+		return commit
+	}
+	return ""
+}
+
+// ideWorkspace works out the folder the IDE has open: VSCODE_CWD, the
+// --folder-uri or a folder given as an argument on the IDE's command
+// line, the cwd of the terminal shell it started, or the IDE's own cwd.
+func ideWorkspace(ancestry []Process, i int) (dir, evidence string) {
+	for j := len(ancestry) - 1; j >= i; j-- {
+		if v := envValue(ancestry[j], "VSCODE_CWD"); v != "" {
+			return v, "environment sets VSCODE_CWD=" + v
+		}
+	}
+	ide := ancestry[i]
+	if dir := ideFolder(strings.Fields(ide.GetCmdline())); dir != "" {
+		return dir, ancestorEvidence(ide, "was given folder "+dir)
+	}
+	if i+1 < len(ancestry) && shells[ancestry[i+1].GetCommand()] {
+		if wd := ancestry[i+1].GetWorkingDir(); wd != "" && wd != "/" {
+			return wd, "terminal shell pid " + itoa(ancestry[i+1].GetPID()) + " runs in " + wd
+		}
+	}
+	if wd := ide.GetWorkingDir(); wd != "" && wd != "/" {
+		return wd, ancestorEvidence(ide, "runs in "+wd)
+	}
+	return "", ""
+}
+
+// ideValueFlags are VS Code and code-server flags whose value is the next
+// argument. Directories among them, such as --logsPath <dir>, are not the
+// workspace; other flags, like --new-window, take no value.
+var ideValueFlags = map[string]bool{
+	"--logsPath": true, "--user-data-dir": true, "--extensions-dir": true,
+	"--server-data-dir": true, "--extensions-download-dir": true,
+	"--builtin-extensions-dir": true, "--agent-folder": true,
+	"--host": true, "--port": true, "--socket-path": true,
+	"--connection-token": true, "--connection-token-file": true,
+	"--locale": true, "--log": true, "--profile": true, "--remote": true,
+	"--file-uri": true, "--install-extension": true, "--uninstall-extension": true,
+	"--bind-addr": true, "--auth": true, "--cert": true, "--cert-key": true,
+	"--config": true, "--proxy-domain": true, "--app-name": true,
+}
+
+// ideFolder finds the folder in an IDE's arguments: a file:// --folder-uri,
+// or else an existing directory given as an argument.
+func ideFolder(args []string) string {
+	for k := 1; k < len(args); k++ {
+		arg := args[k]
+		if uri, ok := strings.CutPrefix(arg, "--folder-uri="); ok {
+			arg = folderURIPath(uri)
+		} else if arg == "--folder-uri" && k+1 < len(args) {
+			k++
+			arg = folderURIPath(args[k])
+		} else if strings.HasPrefix(arg, "-") {
+			if ideValueFlags[arg] {
+				k++
+			}
+			continue
+		}
+		if !filepath.IsAbs(arg) {
+			continue
+		}
+		if fi, err := os.Stat(arg); err == nil && fi.IsDir() {
+			return arg
+		}
+	}
+	return ""
+}
+
+// folderURIPath returns the path of a file:// folder URI, or "" for
+// remote ones such as vscode-remote://.
+func folderURIPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return ""
+	}
+	return u.Path
+}
+
+// tildePath abbreviates user's home directory in path to ~.
+func tildePath(path, user string) string {
+	_, home := lookupUser(user)
+	if home == "" || home == "/" {
+		return path
+	}
+	if path == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(path, home+"/"); ok {
+		return "~/" + rest
+	}
+	return path
+}
+
+// detectIDE finds the editor or IDE nearest the target, noting whether
+// the process was started from the IDE's integrated terminal.
+func detectIDE(ancestry []Process) *Source {
+	for i := len(ancestry) - 1; i >= 0; i-- {
+		p := ancestry[i]
+		name, ok := ideAt(p)
+		if !ok {
+			continue
+		}
+		details := map[string]string{"ide": name}
+		src := &Source{
+			Type:       SourceIDE,
+			Name:       name,
+			Confidence: 0.8,
+			PID:        p.GetPID(),
+			Details:    details,
+			Evidence:   []string{ancestorEvidence(p, "is "+name+" (comm="+p.GetCommand()+")")},
+		}
+		arg0, _, _ := strings.Cut(p.GetCmdline(), " ")
+		if commit := vscodeCommit(arg0); commit != "" {
+			details["server_commit"] = commit
+		}
+
+		from := name
+		for _, q := range ancestry[i+1:] {
+			if shells[q.GetCommand()] {
+				details["terminal"] = "yes"
+				from += " terminal"
+				break
+			}
+		}
+		desc := "Started from " + from
+		if dir, evidence := ideWorkspace(ancestry, i); dir != "" {
+			details["workspace"] = dir
+			desc += " in " + tildePath(dir, p.GetUser())
+			src.Evidence = append(src.Evidence, evidence)
+		}
+		src.Description = desc
+		return src
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// inetd and xinetd service configuration.
+var (
+	inetdConf  = "/etc/inetd.conf"
+	xinetdConf = "/etc/xinetd.conf"
+	xinetdDir  = "/etc/xinetd.d"
+)
+
+// inetdService is one service entry that spawns a server on connection.
+type inetdService struct {
+	Name   string
+	File   string
+	Values map[string]string // xinetd attribute names
+}
+
+// parseXinetd reads the "service name { key = value }" blocks of an
+// xinetd config file.
+func parseXinetd(path string) []inetdService {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var services []inetdService
+	var cur *inetdService
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == '#':
+		case strings.HasPrefix(line, "service "):
+			services = append(services, inetdService{
+				Name:   strings.TrimSpace(strings.TrimPrefix(line, "service ")),
+				File:   path,
+				Values: make(map[string]string),
+			})
+			cur = &services[len(services)-1]
+		case line == "}":
+			cur = nil
+		case cur != nil:
+			// "=", "+=" and "-=" all count as a setting for witr's purposes
+			if key, val, ok := strings.Cut(line, "="); ok {
+				key = strings.TrimRight(strings.TrimSpace(key), "+-")
+				cur.Values[strings.TrimSpace(key)] = strings.TrimSpace(val)
+			}
+		}
+	}
+	return services
+}
+
+// parseInetdConf reads classic inetd.conf lines:
+//
+//	service socket-type protocol wait/nowait user server args...
+func parseInetdConf(path string) []inetdService {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var services []inetdService
+	for _, line := range strings.Split(string(data), "\n") {
+		f := strings.Fields(line)
+		if len(f) < 6 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		s := inetdService{Name: f[0], File: path, Values: map[string]string{
+			"socket_type": f[1],
+			"protocol":    f[2],
+			"wait":        f[3],
+			"user":        f[4],
+			"server":      f[5],
+		}}
+		if len(f) > 6 {
+			s.Values["server_args"] = strings.Join(f[6:], " ")
+		}
+		services = append(services, s)
+	}
+	return services
+}
+
+// inetdDetails finds the service entry whose server the super-server at
+// ancestry[i] spawned for the process below it.
+func inetdDetails(src *Source, ancestry []Process, i int) {
+	if i+1 >= len(ancestry) {
+		return
+	}
+	child := ancestry[i+1]
+	args := strings.Fields(child.GetCmdline())
+	exe := readExe(child.GetPID())
+
+	var services []inetdService
+	if src.Name == "xinetd" {
+		services = parseXinetd(xinetdConf)
+		files, _ := filepath.Glob(xinetdDir + "/*")
+		for _, f := range files {
+			services = append(services, parseXinetd(f)...)
+		}
+	} else {
+		services = parseInetdConf(inetdConf)
+	}
+
+	for _, s := range services {
+		server := s.Values["server"]
+		if server == "" || s.Values["disable"] == "yes" {
+			continue
+		}
+		// tcpd wraps the real server, named in the first argument
+		if filepath.Base(server) == "tcpd" {
+			if a := strings.Fields(s.Values["server_args"]); len(a) > 0 {
+				server = a[0]
+			}
+		}
+		if server != exe && (len(args) == 0 || (server != args[0] && filepath.Base(server) != args[0])) {
+			continue
+		}
+		details := map[string]string{"inetd_service": s.Name, "config_file": s.File}
+		for _, key := range []string{"port", "socket_type", "protocol", "user", "server", "server_args", "wait"} {
+			if v := s.Values[key]; v != "" {
+				details[key] = v
+			}
+		}
+		src.Details = details
+		src.Description = "Spawned on connection by " + src.Name + " for service " + s.Name + " (" + s.File + ")"
+		src.Evidence = append(src.Evidence, "server "+server+" of service "+s.Name+" in "+s.File)
+		return
+	}
+}
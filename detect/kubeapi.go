@@ -0,0 +1,364 @@
+package detect
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// KubeAPI enables querying the Kubernetes API server for a pod's owners.
+// Off by default: witr stays local unless asked.
+var KubeAPI bool
+
+// In-cluster service account credentials.
+var serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+
+const kubeAPITimeout = 2 * time.Second
+
+// kubeClient is a minimal read-only Kubernetes API client.
+type kubeClient struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// kubeObject holds the metadata witr reads from any API object.
+type kubeObject struct {
+	Kind     string
+	Metadata struct {
+		Name            string
+		Namespace       string
+		Labels          map[string]string
+		Annotations     map[string]string
+		OwnerReferences []kubeOwnerRef
+	}
+}
+
+type kubeOwnerRef struct {
+	APIVersion string
+	Kind       string
+	Name       string
+	Controller bool
+}
+
+// controller returns the managing owner, if any.
+func (o *kubeObject) controller() *kubeOwnerRef {
+	for i, ref := range o.Metadata.OwnerReferences {
+		if ref.Controller {
+			return &o.Metadata.OwnerReferences[i]
+		}
+	}
+	return nil
+}
+
+// get decodes the JSON response for an API path into v.
+func (c *kubeClient) get(path string, v any) error {
+	req, err := http.NewRequest(http.MethodGet, c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("kubernetes API %s: %s", path, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// objectPath builds the REST path for a namespaced object.
+func objectPath(apiVersion, kind, namespace, name string) string {
+	prefix := "/apis/" + apiVersion
+	if apiVersion == "v1" {
+		prefix = "/api/v1"
+	}
+	return prefix + "/namespaces/" + url.PathEscape(namespace) + "/" +
+		strings.ToLower(kind) + "s/" + url.PathEscape(name)
+}
+
+// ownerChain walks controller ownerReferences up from a pod, e.g.
+// Pod → ReplicaSet → Deployment or Pod → Job → CronJob. It returns every
+// object visited, pod first, stopping quietly at the first error.
+func (c *kubeClient) ownerChain(namespace, pod string) ([]kubeObject, error) {
+	var obj kubeObject
+	if err := c.get(objectPath("v1", "Pod", namespace, pod), &obj); err != nil {
+		return nil, err
+	}
+	obj.Kind = "Pod"
+	chain := []kubeObject{obj}
+	for depth := 0; depth < 5; depth++ {
+		ref := chain[len(chain)-1].controller()
+		if ref == nil {
+			break
+		}
+		var owner kubeObject
+		if err := c.get(objectPath(ref.APIVersion, ref.Kind, namespace, ref.Name), &owner); err != nil {
+			// Still report the owner we know about
+			owner.Kind = ref.Kind
+			owner.Metadata.Name = ref.Name
+			chain = append(chain, owner)
+			break
+		}
+		owner.Kind = ref.Kind
+		chain = append(chain, owner)
+	}
+	return chain, nil
+}
+
+// helmRelease finds Helm's release markers anywhere in the chain.
+func helmRelease(chain []kubeObject) (release, chart string) {
+	for i := len(chain) - 1; i >= 0; i-- {
+		m := chain[i].Metadata
+		if release == "" {
+			release = m.Annotations["meta.helm.sh/release-name"]
+			if release == "" && m.Labels["app.kubernetes.io/managed-by"] == "Helm" {
+				release = m.Labels["app.kubernetes.io/instance"]
+			}
+		}
+		if chart == "" {
+			chart = m.Labels["helm.sh/chart"]
+		}
+	}
+	return release, chart
+}
+
+// addOwners enriches a pod source with its owner chain and Helm release.
+func (c *kubeClient) addOwners(pod *kubePod, src *Source) error {
+	chain, err := c.ownerChain(pod.Namespace, pod.Name)
+	if err != nil {
+		return err
+	}
+	var owners []string
+	for _, obj := range chain[1:] {
+		owners = append(owners, obj.Kind+"/"+obj.Metadata.Name)
+	}
+	if len(owners) > 0 {
+		src.Details["owner_chain"] = strings.Join(owners, " → ")
+		src.Details["controller"] = owners[len(owners)-1]
+		src.Evidence = append(src.Evidence, "Kubernetes API ownerReferences lead to "+owners[len(owners)-1])
+		src.Description += ", managed by " + owners[len(owners)-1]
+	}
+	if release, chart := helmRelease(chain); release != "" {
+		src.Details["helm_release"] = release
+		if chart != "" {
+			src.Details["helm_chart"] = chart
+		}
+		src.Description += " (Helm release " + release + ")"
+	}
+	return nil
+}
+
+// newKubeClient uses the in-cluster service account when available,
+// otherwise the current context of $KUBECONFIG or ~/.kube/config.
+func newKubeClient() (*kubeClient, error) {
+	if host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT"); host != "" && port != "" {
+		if token, err := os.ReadFile(serviceAccountDir + "/token"); err == nil {
+			tlsConfig := &tls.Config{}
+			if ca, err := os.ReadFile(serviceAccountDir + "/ca.crt"); err == nil {
+				tlsConfig.RootCAs = x509.NewCertPool()
+				tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+			}
+			return &kubeClient{
+				base:  "https://" + joinHostPort(host, port),
+				token: strings.TrimSpace(string(token)),
+				http:  kubeHTTPClient(tlsConfig),
+			}, nil
+		}
+	}
+
+	path := os.Getenv("KUBECONFIG")
+	if i := strings.IndexByte(path, os.PathListSeparator); i != -1 {
+		path = path[:i]
+	}
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		path = home + "/.kube/config"
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return kubeconfigClient(parseKubeconfig(string(data)), filepath.Dir(path))
+}
+
+func joinHostPort(host, port string) string {
+	if strings.Contains(host, ":") {
+		return "[" + host + "]:" + port
+	}
+	return host + ":" + port
+}
+
+func kubeHTTPClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Timeout:   kubeAPITimeout,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig},
+	}
+}
+
+// kubeconfig is the flattened current context of a kubeconfig file.
+type kubeconfig map[string]string
+
+// parseKubeconfig reads the fields witr needs from a kubeconfig without a
+// YAML dependency. It understands the layout kubectl writes: top-level
+// clusters/contexts/users lists whose entries are flattened to key/value
+// pairs, e.g. "server", "token", "certificate-authority-data".
+func parseKubeconfig(data string) kubeconfig {
+	lists := map[string][]map[string]string{}
+	top := map[string]string{}
+	var section string
+	var item map[string]string
+	listIndent, skipIndent := -1, -1
+	for _, raw := range strings.Split(data, "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		indent := len(raw) - len(strings.TrimLeft(raw, " "))
+		if indent == 0 && line[0] != '-' {
+			key, val, _ := strings.Cut(line, ":")
+			section, item, listIndent, skipIndent = key, nil, -1, -1
+			if val = strings.TrimSpace(val); val != "" {
+				top[key] = strings.Trim(val, `"'`)
+			}
+			continue
+		}
+		if skipIndent != -1 {
+			if indent > skipIndent || (indent == skipIndent && line[0] == '-') {
+				continue
+			}
+			skipIndent = -1
+		}
+		if strings.HasPrefix(line, "- ") {
+			if listIndent == -1 {
+				listIndent = indent
+			}
+			if indent > listIndent {
+				// Nested list inside an entry, e.g. extensions
+				skipIndent = indent
+				continue
+			}
+			item = map[string]string{}
+			lists[section] = append(lists[section], item)
+			line = strings.TrimPrefix(line, "- ")
+		}
+		key, val, ok := strings.Cut(line, ":")
+		if !ok || item == nil {
+			continue
+		}
+		key = strings.TrimSpace(key)
+		if val = strings.Trim(strings.TrimSpace(val), `"'`); val != "" {
+			item[key] = val
+		} else if key == "exec" || key == "auth-provider" {
+			// Plugin credentials witr cannot use; note them and skip
+			// their settings, whose keys would clash with the entry's
+			item[key] = "true"
+			skipIndent = indent
+		}
+	}
+
+	find := func(section, name string) map[string]string {
+		for _, m := range lists[section] {
+			if m["name"] == name {
+				return m
+			}
+		}
+		return nil
+	}
+	cfg := kubeconfig{}
+	ctx := find("contexts", top["current-context"])
+	if ctx == nil {
+		return cfg
+	}
+	for k, v := range find("clusters", ctx["cluster"]) {
+		cfg[k] = v
+	}
+	for k, v := range find("users", ctx["user"]) {
+		cfg[k] = v
+	}
+	return cfg
+}
+
+// kubeconfigClient builds a client from a parsed kubeconfig context.
+// Relative certificate, key and token file paths are taken from dir, the
+// kubeconfig's directory, as kubectl does. Users that authenticate
+// through an exec plugin or auth provider are refused rather than sent to
+// the API server without credentials.
+func kubeconfigClient(cfg kubeconfig, dir string) (*kubeClient, error) {
+	if cfg["server"] == "" {
+		return nil, fmt.Errorf("kubeconfig: no server for current context")
+	}
+	switch {
+	case cfg["exec"] != "":
+		return nil, fmt.Errorf("kubeconfig: user %s uses an exec credential plugin, which witr does not run", cfg["name"])
+	case cfg["auth-provider"] != "":
+		return nil, fmt.Errorf("kubeconfig: user %s uses an auth-provider, which witr does not support", cfg["name"])
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg["insecure-skip-tls-verify"] == "true"}
+
+	ca := decodeOrRead(cfg["certificate-authority-data"], kubeconfigPath(dir, cfg["certificate-authority"]))
+	if ca != nil {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		tlsConfig.RootCAs.AppendCertsFromPEM(ca)
+	}
+	cert := decodeOrRead(cfg["client-certificate-data"], kubeconfigPath(dir, cfg["client-certificate"]))
+	key := decodeOrRead(cfg["client-key-data"], kubeconfigPath(dir, cfg["client-key"]))
+	if cert != nil && key != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	token := cfg["token"]
+	if token == "" && cfg["tokenFile"] != "" {
+		if data, err := os.ReadFile(kubeconfigPath(dir, cfg["tokenFile"])); err == nil {
+			token = strings.TrimSpace(string(data))
+		}
+	}
+	return &kubeClient{
+		base:  strings.TrimSuffix(cfg["server"], "/"),
+		token: token,
+		http:  kubeHTTPClient(tlsConfig),
+	}, nil
+}
+
+// kubeconfigPath resolves a path from a kubeconfig against its directory.
+func kubeconfigPath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// decodeOrRead returns inline base64 data, or the contents of path.
+func decodeOrRead(data, path string) []byte {
+	if data != "" {
+		if b, err := base64.StdEncoding.DecodeString(data); err == nil {
+			return b
+		}
+	}
+	if path != "" {
+		if b, err := os.ReadFile(path); err == nil {
+			return b
+		}
+	}
+	return nil
+}
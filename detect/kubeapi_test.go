@@ -0,0 +1,296 @@
+package detect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// fakeKubeAPI serves API objects by path, refusing requests without the
+// expected bearer token and answering 403 for paths in forbidden.
+func fakeKubeAPI(t *testing.T, objects map[string]string, forbidden ...string) *kubeClient {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer test-token" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		for _, path := range forbidden {
+			if r.URL.Path == path {
+				http.Error(w, `{"kind":"Status","reason":"Forbidden"}`, http.StatusForbidden)
+				return
+			}
+		}
+		body, ok := objects[r.URL.Path]
+		if !ok {
+			http.Error(w, `{"kind":"Status","reason":"NotFound"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return &kubeClient{base: srv.URL, token: "test-token", http: srv.Client()}
+}
+
+const (
+	podPath        = "/api/v1/namespaces/shop/pods/web-7d9f-abcde"
+	replicaSetPath = "/apis/apps/v1/namespaces/shop/replicasets/web-7d9f"
+	deploymentPath = "/apis/apps/v1/namespaces/shop/deployments/web"
+)
+
+var kubeObjects = map[string]string{
+	podPath: `{"metadata": {"name": "web-7d9f-abcde", "namespace": "shop",
+		"ownerReferences": [{"apiVersion": "apps/v1", "kind": "ReplicaSet", "name": "web-7d9f", "controller": true}]}}`,
+	replicaSetPath: `{"metadata": {"name": "web-7d9f", "namespace": "shop",
+		"labels": {"helm.sh/chart": "web-1.4.0"},
+		"ownerReferences": [{"apiVersion": "apps/v1", "kind": "Deployment", "name": "web", "controller": true}]}}`,
+	deploymentPath: `{"metadata": {"name": "web", "namespace": "shop",
+		"annotations": {"meta.helm.sh/release-name": "shop-web"}}}`,
+}
+
+func testPodSource() (*kubePod, *Source) {
+	pod := &kubePod{UID: "1234", QoS: "burstable", Name: "web-7d9f-abcde", Namespace: "shop"}
+	return pod, pod.source()
+}
+
+func TestKubeOwnerChain(t *testing.T) {
+	client := fakeKubeAPI(t, kubeObjects)
+	pod, src := testPodSource()
+	if err := client.addOwners(pod, src); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"owner_chain":  "ReplicaSet/web-7d9f → Deployment/web",
+		"controller":   "Deployment/web",
+		"helm_release": "shop-web",
+		"helm_chart":   "web-1.4.0",
+	} {
+		if got := src.Details[key]; got != want {
+			t.Errorf("Details[%s] = %q, want %q", key, got, want)
+		}
+	}
+	if !strings.Contains(src.Description, "managed by Deployment/web") {
+		t.Errorf("Description = %q, want it to name the Deployment", src.Description)
+	}
+}
+
+func TestKubeOwnerChainMissingOwner(t *testing.T) {
+	objects := map[string]string{podPath: kubeObjects[podPath]}
+	client := fakeKubeAPI(t, objects)
+	pod, src := testPodSource()
+	if err := client.addOwners(pod, src); err != nil {
+		t.Fatal(err)
+	}
+	// The ReplicaSet is gone, but the pod's reference still names it
+	if got, want := src.Details["owner_chain"], "ReplicaSet/web-7d9f"; got != want {
+		t.Errorf("owner_chain = %q, want %q", got, want)
+	}
+}
+
+func TestKubeOwnerChainForbidden(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		forbidden string
+		wantErr   bool
+		wantChain string
+	}{
+		{name: "pod", forbidden: podPath, wantErr: true},
+		{name: "deployment", forbidden: deploymentPath, wantChain: "ReplicaSet/web-7d9f → Deployment/web"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client := fakeKubeAPI(t, kubeObjects, tt.forbidden)
+			pod, src := testPodSource()
+			err := client.addOwners(pod, src)
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "403") {
+					t.Errorf("addOwners error = %v, want 403", err)
+				}
+				if _, ok := src.Details["owner_chain"]; ok {
+					t.Errorf("owner_chain set despite error: %q", src.Details["owner_chain"])
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := src.Details["owner_chain"]; got != tt.wantChain {
+				t.Errorf("owner_chain = %q, want %q", got, tt.wantChain)
+			}
+		})
+	}
+}
+
+const twoContexts = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: Q0EK
+    server: https://prod.example.com:6443
+  name: prod
+- cluster:
+    server: https://127.0.0.1:6443
+    insecure-skip-tls-verify: true
+    extensions:
+    - extension:
+        provider: kind
+      name: kind
+  name: kind-dev
+contexts:
+- context:
+    cluster: prod
+    namespace: shop
+    user: ops
+  name: prod-ops
+- context:
+    cluster: kind-dev
+    user: kind-admin
+  name: kind-dev
+current-context: %s
+preferences: {}
+users:
+- name: ops
+  user:
+    token: "ops-token"
+- name: kind-admin
+  user:
+    client-certificate-data: Q0VSVAo=
+    client-key-data: S0VZCg==
+`
+
+func TestParseKubeconfig(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want kubeconfig
+	}{
+		{
+			name: "first context",
+			data: strings.Replace(twoContexts, "%s", "prod-ops", 1),
+			want: kubeconfig{
+				"name":                       "ops",
+				"server":                     "https://prod.example.com:6443",
+				"certificate-authority-data": "Q0EK",
+				"token":                      "ops-token",
+			},
+		},
+		{
+			name: "second context, nested extensions skipped",
+			data: strings.Replace(twoContexts, "%s", `"kind-dev"`, 1),
+			want: kubeconfig{
+				"name":                     "kind-admin",
+				"server":                   "https://127.0.0.1:6443",
+				"insecure-skip-tls-verify": "true",
+				"client-certificate-data":  "Q0VSVAo=",
+				"client-key-data":          "S0VZCg==",
+			},
+		},
+		{
+			name: "missing current-context",
+			data: strings.Replace(twoContexts, "current-context: %s\n", "", 1),
+			want: kubeconfig{},
+		},
+		{
+			name: "current-context names no context",
+			data: strings.Replace(twoContexts, "%s", "staging", 1),
+			want: kubeconfig{},
+		},
+		{
+			name: "exec plugin",
+			data: `clusters:
+- name: eks
+  cluster:
+    server: https://eks.example.com
+contexts:
+- name: eks
+  context:
+    cluster: eks
+    user: eks-user
+current-context: eks
+users:
+- name: eks-user
+  user:
+    exec:
+      apiVersion: client.authentication.k8s.io/v1beta1
+      command: aws
+      args:
+      - eks
+      - get-token
+      env:
+      - name: AWS_PROFILE
+        value: prod
+`,
+			want: kubeconfig{"name": "eks-user", "server": "https://eks.example.com", "exec": "true"},
+		},
+		{
+			name: "auth-provider",
+			data: `clusters:
+- name: gke
+  cluster:
+    server: https://gke.example.com
+contexts:
+- name: gke
+  context:
+    cluster: gke
+    user: gke-user
+current-context: gke
+users:
+- name: gke-user
+  user:
+    auth-provider:
+      name: gcp
+      config:
+        cmd-path: gcloud
+`,
+			want: kubeconfig{"name": "gke-user", "server": "https://gke.example.com", "auth-provider": "true"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseKubeconfig(tt.data); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseKubeconfig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKubeconfigClient(t *testing.T) {
+	// Relative paths are resolved against the kubeconfig's directory,
+	// not witr's working directory
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "token"), []byte("t\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		cfg     kubeconfig
+		wantErr string
+	}{
+		{name: "token", cfg: kubeconfig{"server": "https://k8s.example.com/", "token": "t"}},
+		{name: "relative token file", cfg: kubeconfig{"server": "https://k8s.example.com", "tokenFile": "token"}},
+		{name: "no server", cfg: kubeconfig{"token": "t"}, wantErr: "no server"},
+		{name: "exec", cfg: kubeconfig{"name": "eks-user", "server": "https://eks.example.com", "exec": "true"}, wantErr: "exec credential plugin"},
+		{name: "auth-provider", cfg: kubeconfig{"name": "gke-user", "server": "https://gke.example.com", "auth-provider": "true"}, wantErr: "auth-provider"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := kubeconfigClient(tt.cfg, dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("kubeconfigClient() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if client.base != "https://k8s.example.com" || client.token != "t" {
+				t.Errorf("kubeconfigClient() = base %q token %q", client.base, client.token)
+			}
+		})
+	}
+}
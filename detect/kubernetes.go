@@ -0,0 +1,161 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Kubelet and CRI state directories.
+var (
+	kubeletPodsDir = "/var/lib/kubelet/pods"
+	podLogsDir     = "/var/log/pods"
+	containerLogs  = "/var/log/containers"
+)
+
+// kubePod identifies a pod container from its cgroup path.
+type kubePod struct {
+	UID           string
+	QoS           string // guaranteed, burstable or besteffort
+	ContainerID   string
+	Runtime       string
+	Name          string
+	Namespace     string
+	ContainerName string
+}
+
+// Scope prefixes used by CRI runtimes with the systemd cgroup driver.
+var criScopePrefixes = map[string]string{
+	"cri-containerd-": "containerd",
+	"crio-":           "cri-o",
+	"docker-":         "docker",
+}
+
+// parseKubePod extracts the pod UID, QoS class and container ID from any
+// line of /proc/<pid>/cgroup. Handles both cgroup drivers:
+//
+//	/kubepods/burstable/pod<uid>/<id>
+//	/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod<uid>.slice/cri-containerd-<id>.scope
+func parseKubePod(data string) *kubePod {
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 3 || !strings.Contains(parts[2], "kubepods") {
+			continue
+		}
+		pod := &kubePod{QoS: "guaranteed"}
+		for _, seg := range strings.Split(parts[2], "/") {
+			seg = strings.TrimSuffix(seg, ".slice")
+			switch {
+			case seg == "burstable" || strings.HasSuffix(seg, "-burstable"):
+				pod.QoS = "burstable"
+			case seg == "besteffort" || strings.HasSuffix(seg, "-besteffort"):
+				pod.QoS = "besteffort"
+			case strings.Contains(seg, "pod") && pod.UID == "" && seg != "kubepods":
+				uid := seg[strings.LastIndex(seg, "pod")+3:]
+				pod.UID = strings.ReplaceAll(uid, "_", "-")
+			case isHexID(seg):
+				pod.ContainerID = seg
+			case strings.HasSuffix(seg, ".scope"):
+				for prefix, runtime := range criScopePrefixes {
+					if id := strings.TrimSuffix(strings.TrimPrefix(seg, prefix), ".scope"); isHexID(id) {
+						pod.ContainerID, pod.Runtime = id, runtime
+					}
+				}
+			}
+		}
+		if pod.UID != "" {
+			return pod
+		}
+	}
+	return nil
+}
+
+// resolve fills in the pod name, namespace and container name from the
+// CRI log layout (/var/log/pods/<ns>_<name>_<uid>/) and kubelet state.
+func (pod *kubePod) resolve() {
+	if matches, _ := filepath.Glob(podLogsDir + "/*_*_" + pod.UID); len(matches) > 0 {
+		if parts := strings.SplitN(filepath.Base(matches[0]), "_", 3); len(parts) == 3 {
+			pod.Namespace, pod.Name = parts[0], parts[1]
+		}
+	}
+	if pod.Name == "" {
+		pod.Name = podHostname(kubeletPodsDir + "/" + pod.UID + "/etc-hosts")
+	}
+
+	// /var/log/containers/<pod>_<ns>_<container>-<id>.log
+	if pod.ContainerID != "" {
+		if matches, _ := filepath.Glob(containerLogs + "/*-" + pod.ContainerID + ".log"); len(matches) > 0 {
+			base := strings.TrimSuffix(filepath.Base(matches[0]), "-"+pod.ContainerID+".log")
+			if parts := strings.SplitN(base, "_", 3); len(parts) == 3 {
+				pod.ContainerName = parts[2]
+			}
+		}
+	}
+	if pod.ContainerName == "" {
+		if entries, err := os.ReadDir(kubeletPodsDir + "/" + pod.UID + "/containers"); err == nil && len(entries) == 1 {
+			pod.ContainerName = entries[0].Name()
+		}
+	}
+}
+
+// podHostname reads the pod name from the hosts file kubelet writes,
+// whose last entry maps the pod IP to its hostname.
+func podHostname(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if fields := strings.Fields(lines[len(lines)-1]); len(fields) > 1 && fields[0][0] != '#' {
+		return fields[1]
+	}
+	return ""
+}
+
+// source describes the pod as a Kubernetes source.
+func (pod *kubePod) source() *Source {
+	details := map[string]string{"pod_uid": pod.UID, "qos_class": pod.QoS}
+	for k, v := range map[string]string{
+		"pod_name":       pod.Name,
+		"namespace":      pod.Namespace,
+		"container_id":   pod.ContainerID,
+		"container_name": pod.ContainerName,
+		"runtime":        pod.Runtime,
+	} {
+		if v != "" {
+			details[k] = v
+		}
+	}
+
+	name := pod.Name
+	switch {
+	case name == "":
+		name = "pod " + pod.UID
+	case pod.Namespace != "":
+		name = pod.Namespace + "/" + name
+	}
+	desc := "Runs in Kubernetes pod " + name
+	if pod.ContainerName != "" {
+		desc += ", container " + pod.ContainerName
+	}
+	desc += " (QoS " + pod.QoS + ")"
+	return &Source{Type: SourceKubernetes, Name: name, Confidence: 0.95, Description: desc, Details: details}
+}
+
+// kubernetesSource describes a pod, asking the API server for its owners
+// when KubeAPI is set. API failures leave the local answer intact.
+func kubernetesSource(pod *kubePod) *Source {
+	pod.resolve()
+	src := pod.source()
+	if !KubeAPI || pod.Name == "" || pod.Namespace == "" {
+		return src
+	}
+	client, err := newKubeClient()
+	if err == nil {
+		err = client.addOwners(pod, src)
+	}
+	if err != nil {
+		src.Details["owner_chain_error"] = err.Error()
+	}
+	return src
+}
@@ -0,0 +1,180 @@
+package detect
+
+import (
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Layers returns every party responsible for keeping the target alive,
+// outermost first, ending with the target itself. A gunicorn worker in a
+// compose container might give:
+//
+//	docker.service → dockerd → containerd-shim-runc-v2 → web/app → tini → gunicorn → target
+//
+// The layers come from the registered detectors, given as the verdicts
+// Explain returned for the full ancestry: each match is placed at the
+// ancestor it names, and its detector is asked again about the ancestors
+// above it to find outer matches of the same kind, such as a shell
+// started from another shell. The systemd units and container runtime
+// the ancestry passes through are added between them.
+func Layers(ancestry []Process, verdicts []Verdict) []Source {
+	if len(ancestry) == 0 {
+		return nil
+	}
+	target := ancestry[len(ancestry)-1]
+	index := make(map[int]int, len(ancestry))
+	for i, p := range ancestry {
+		index[p.GetPID()] = i
+	}
+
+	// Matches placed at an ancestor, and those not tied to one
+	type match struct {
+		at, priority int
+		src          *Source
+	}
+	var matches []match
+	var unplaced []Source
+	for _, v := range verdicts {
+		src := v.Source
+		if src == nil {
+			continue
+		}
+		at, ok := index[src.PID]
+		if !ok || src.PID <= 1 {
+			unplaced = append(unplaced, *src)
+			continue
+		}
+		d := detectorNamed(v.Detector)
+		for {
+			matches = append(matches, match{at, v.Priority, src})
+			if d == nil || at == 0 {
+				break
+			}
+			if src = d.Detect(ancestry[:at]); src == nil {
+				break
+			}
+			if next, ok := index[src.PID]; ok && next < at && src.PID > 1 {
+				at = next
+			} else {
+				break
+			}
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].at != matches[j].at {
+			return matches[i].at < matches[j].at
+		}
+		return matches[i].priority < matches[j].priority
+	})
+
+	var layers []Source
+	add := func(src *Source, pid int, evidence string) {
+		layer := *src
+		if layer.PID == 0 {
+			layer.PID = pid
+		}
+		if len(layer.Evidence) == 0 && evidence != "" {
+			layer.Evidence = []string{evidence}
+		}
+		if n := len(layers); n > 0 && layers[n-1].Type == layer.Type && layers[n-1].Name == layer.Name {
+			// e.g. cron forking a cron child, bash running bash; the
+			// inner match knows most about the pair
+			layers[n-1] = layer
+			return
+		}
+		layers = append(layers, layer)
+	}
+
+	// Sandboxes and quadlet containers run as a unit of their own and
+	// stand in for it
+	emitted := make(map[*Source]bool)
+	var lastUnit string
+	addUnit := func(unit string, pid int) {
+		if unit == lastUnit || (!strings.HasSuffix(unit, ".service") && sessionID(unit) == "") {
+			return
+		}
+		lastUnit = unit
+		evidence := "cgroup of pid " + itoa(pid) + " is in " + unit
+		for _, m := range matches {
+			if m.src.Details["systemd_unit"] == unit && !emitted[m.src] {
+				emitted[m.src] = true
+				add(m.src, pid, evidence)
+				return
+			}
+		}
+		add(&Source{Type: SourceSystemd, Name: unit, Confidence: 0.9}, pid, evidence)
+	}
+
+	next := 0
+	for i, p := range ancestry {
+		pid := p.GetPID()
+		if pid != 1 {
+			// dockerd hands containers to containerd, so it never shows
+			// up as an ancestor; add it ahead of the shims it asked for
+			shim := parseShim(pid, p.GetCmdline())
+			if shim != nil && shim.Namespace == "moby" {
+				if dockerd := dockerdPID(); dockerd > 0 {
+					if u := parseSystemdUnit(readCgroup(dockerd)); u != nil {
+						addUnit(u.Unit, dockerd)
+					}
+					add(&Source{Type: SourceRuntime, Name: "dockerd", Confidence: 0.9}, dockerd,
+						"containerd namespace moby belongs to dockerd (pid "+itoa(dockerd)+")")
+				}
+			}
+			addUnit(p.GetService(), pid)
+			if shim != nil {
+				add(&Source{Type: SourceRuntime, Name: shim.Kind, Confidence: 0.9}, pid,
+					"ancestor pid "+itoa(pid)+" comm="+p.GetCommand()+" is a "+shim.Runtime+" shim")
+			}
+		}
+		for ; next < len(matches) && matches[next].at == i; next++ {
+			if m := matches[next]; !emitted[m.src] {
+				emitted[m.src] = true
+				add(m.src, pid, "")
+			}
+		}
+	}
+
+	// Sources not tied to an ancestor, such as the unit systemd reports
+	// or launchd, fill in a layer of the same party or else go first
+	for _, src := range unplaced {
+		found, same := false, -1
+		for i, l := range layers {
+			found = found || l.Type == src.Type
+			if l.Type == src.Type && l.Name == src.Name {
+				same = i
+			}
+		}
+		switch {
+		case same >= 0:
+			pid := layers[same].PID
+			layers[same] = src
+			if src.PID == 0 {
+				layers[same].PID = pid
+			}
+		case !found:
+			layers = append([]Source{src}, layers...)
+		}
+	}
+
+	layers = append(layers, Source{
+		Type:       SourceProcess,
+		Name:       target.GetCommand(),
+		Confidence: 1,
+		PID:        target.GetPID(),
+		Evidence:   []string{"target process"},
+	})
+	return layers
+}
+
+// dockerdPID reads the Docker daemon's PID file.
+func dockerdPID() int {
+	data, err := os.ReadFile(dockerPidFile)
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	return pid
+}
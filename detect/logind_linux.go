@@ -0,0 +1,150 @@
+//go:build linux
+
+package detect
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// systemd-logind state. The files are documented as private but have
+// been stable KEY=value lists for years; loginctl reads the same data.
+var (
+	logindSessionsDir = "/run/systemd/sessions"
+	logindUsersDir    = "/run/systemd/users"
+	lingerDir         = "/var/lib/systemd/linger"
+)
+
+// logindTime converts a REALTIME= value, microseconds since the epoch.
+func logindTime(usec string) time.Time {
+	n, err := strconv.ParseInt(usec, 10, 64)
+	if err != nil || n == 0 {
+		return time.Time{}
+	}
+	return time.UnixMicro(n)
+}
+
+// userLingers reports whether loginctl enable-linger is set for user,
+// keeping their user manager running without a login.
+func userLingers(user string) bool {
+	if user == "" {
+		return false
+	}
+	_, err := os.Stat(lingerDir + "/" + user)
+	return err == nil
+}
+
+// sessionDetails describes logind session id, or returns nil when logind
+// no longer knows it.
+func sessionDetails(id string) map[string]string {
+	s := readKeyValues(logindSessionsDir + "/" + id)
+	if s == nil {
+		return nil
+	}
+	details := map[string]string{"session_id": id}
+	for key, field := range map[string]string{
+		"session_user":    "USER",
+		"session_uid":     "UID",
+		"session_state":   "STATE",
+		"session_type":    "TYPE",
+		"session_class":   "CLASS",
+		"session_service": "SERVICE",
+		"tty":             "TTY",
+		"seat":            "SEAT",
+		"remote_host":     "REMOTE_HOST",
+		"remote_user":     "REMOTE_USER",
+		"leader_pid":      "LEADER",
+	} {
+		if v := s[field]; v != "" {
+			details[key] = v
+		}
+	}
+	if t := logindTime(s["REALTIME"]); !t.IsZero() {
+		details["session_start"] = t.Format("2006-01-02 15:04:05")
+	}
+	if userLingers(s["USER"]) {
+		details["linger"] = "yes"
+	}
+	return details
+}
+
+// addLoginSession attaches the logind session the target runs in to src,
+// so a shell or detached process says which login it belongs to.
+func addLoginSession(src *Source, target Process) {
+	u := parseSystemdUnit(readCgroup(target.GetPID()))
+	if u == nil {
+		return
+	}
+	id := sessionID(u.Unit)
+	if id == "" {
+		return
+	}
+	sd := sessionDetails(id)
+	if sd == nil {
+		return
+	}
+	if src.Details == nil {
+		src.Details = make(map[string]string)
+	}
+	for k, v := range sd {
+		// what the detector saw itself, such as an ssh tty, stands
+		if _, ok := src.Details[k]; !ok {
+			src.Details[k] = v
+		}
+	}
+	src.Evidence = append(src.Evidence, logindSessionsDir+"/"+id+" lists user "+sd["session_user"])
+}
+
+// sessionDescription phrases a session, e.g. "Login session 42 of alice
+// via sshd from 10.0.4.12, since 2026-10-16 09:13:02".
+func sessionDescription(d map[string]string) string {
+	desc := "Login session " + d["session_id"]
+	if u := d["session_user"]; u != "" {
+		desc += " of " + u
+	}
+	if s := d["session_service"]; s != "" {
+		desc += " via " + s
+	}
+	if h := d["remote_host"]; h != "" {
+		desc += " from " + h
+	} else if t := d["tty"]; t != "" {
+		desc += " on " + t
+	} else if seat := d["seat"]; seat != "" {
+		desc += " on " + seat
+	}
+	if st := d["session_start"]; st != "" {
+		desc += ", since " + st
+	}
+	if d["session_state"] == "closing" {
+		desc += " (logged out, closing)"
+	}
+	return desc
+}
+
+// sessionWarnings flags processes that keep a login alive after the user
+// has gone: a session stuck closing or already gone, or a user manager
+// kept running by lingering.
+func sessionWarnings(target Process) []string {
+	u := parseSystemdUnit(readCgroup(target.GetPID()))
+	if u == nil {
+		return nil
+	}
+	if id := sessionID(u.Unit); id != "" {
+		d := sessionDetails(id)
+		switch {
+		case d == nil:
+			return []string{"Process outlived its login session " + id + " (logind no longer tracks it)"}
+		case d["session_state"] == "closing":
+			return []string{"Process keeps login session " + id + " of " + d["session_user"] + " open after logout"}
+		}
+		return nil
+	}
+	if u.Manager != "system" && u.UID != "" {
+		user := readKeyValues(logindUsersDir + "/" + u.UID)
+		if user["STATE"] == "lingering" {
+			return []string{"Process runs in the user manager of " + user["NAME"] + ", kept alive by lingering with no active login"}
+		}
+	}
+	return nil
+}
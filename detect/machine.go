@@ -0,0 +1,151 @@
+package detect
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// machinesDir is where systemd-machined records registered machines.
+var machinesDir = "/run/systemd/machines"
+
+// parseLXC finds an LXC/LXD/Incus container name in any line of
+// /proc/<pid>/cgroup: /lxc.payload.<name>/... (LXC 4+) or /lxc/<name>/...
+func parseLXC(data string) string {
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		segs := strings.Split(parts[2], "/")
+		for i, seg := range segs {
+			switch {
+			case strings.HasPrefix(seg, "lxc.payload."):
+				return strings.TrimPrefix(seg, "lxc.payload.")
+			case seg == "lxc" && i+1 < len(segs) && segs[i+1] != "":
+				return segs[i+1]
+			}
+		}
+	}
+	return ""
+}
+
+// lxcManager tells LXD and Incus containers from plain LXC by the
+// monitor process that spawned the container's init:
+//
+//	[lxc monitor] /var/snap/lxd/common/lxd/containers web01
+func lxcManager(ancestry []Process) string {
+	for _, p := range ancestry {
+		cmdline := p.GetCmdline()
+		if !strings.HasPrefix(cmdline, "[lxc monitor]") {
+			continue
+		}
+		switch {
+		case strings.Contains(cmdline, "/incus/"):
+			return "incus"
+		case strings.Contains(cmdline, "/lxd/"):
+			return "lxd"
+		}
+	}
+	return "lxc"
+}
+
+var lxcLabels = map[string]string{"lxd": "LXD", "incus": "Incus", "lxc": "LXC"}
+
+// lxcSource describes an LXC-family system container.
+func lxcSource(name string, ancestry []Process) *Source {
+	manager := lxcManager(ancestry)
+	return &Source{
+		Type:        SourceContainer,
+		Name:        name,
+		Confidence:  0.9,
+		Description: lxcLabels[manager] + " container " + name,
+		Details:     map[string]string{"runtime": manager, "container_name": name},
+	}
+}
+
+// parseMachine finds a machined-registered machine in any line of
+// /proc/<pid>/cgroup: /machine.slice/machine-<name>.scope, with systemd's
+// unit-name escaping (\x2d for "-") undone.
+func parseMachine(data string) string {
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		for _, seg := range strings.Split(parts[2], "/") {
+			if strings.HasPrefix(seg, "machine-") && strings.HasSuffix(seg, ".scope") {
+				return unescapeUnit(strings.TrimSuffix(strings.TrimPrefix(seg, "machine-"), ".scope"))
+			}
+		}
+	}
+	return ""
+}
+
+// unescapeUnit reverses systemd's \xNN escaping in unit names.
+func unescapeUnit(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) && s[i+1] == 'x' {
+			if c, err := strconv.ParseUint(s[i+2:i+4], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// readKeyValues parses the KEY=value files systemd keeps under /run.
+func readKeyValues(path string) map[string]string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	kv := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if key, val, ok := strings.Cut(line, "="); ok && !strings.HasPrefix(key, "#") {
+			kv[key] = val
+		}
+	}
+	return kv
+}
+
+var machineLabels = map[string]string{
+	"systemd-nspawn": "nspawn machine",
+	"libvirt-qemu":   "libvirt VM",
+}
+
+// machineSource describes a machine registered with systemd-machined,
+// such as a systemd-nspawn container.
+func machineSource(name string) *Source {
+	details := map[string]string{"container_name": name, "runtime": "machined"}
+	if m := readKeyValues(machinesDir + "/" + name); m != nil {
+		if m["SERVICE"] != "" {
+			details["runtime"] = m["SERVICE"]
+		}
+		for key, field := range map[string]string{
+			"machine_class":  "CLASS",
+			"leader_pid":     "LEADER",
+			"root_directory": "ROOT",
+			"machine_unit":   "SCOPE",
+		} {
+			if v := m[field]; v != "" {
+				details[key] = v
+			}
+		}
+	}
+	label, ok := machineLabels[details["runtime"]]
+	if !ok {
+		label = "machine"
+	}
+	return &Source{
+		Type:        SourceContainer,
+		Name:        name,
+		Confidence:  0.9,
+		Description: label + " " + name,
+		Details:     details,
+	}
+}
@@ -0,0 +1,149 @@
+package detect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// pm2App is an app entry of dump.pm2, the process list `pm2 save` writes
+// and `pm2 resurrect` restores at boot.
+type pm2App struct {
+	Name        string `json:"name"`
+	PMID        any    `json:"pm_id"`
+	ExecPath    string `json:"pm_exec_path"`
+	ExecMode    string `json:"exec_mode"`
+	Watch       any    `json:"watch"` // bool, or the paths being watched
+	RestartTime int    `json:"restart_time"`
+}
+
+// pm2Home finds the PM2 home directory. The God daemon names it in its
+// process title, "PM2 v5.3.0: God Daemon (/home/app/.pm2)"; apps inherit
+// PM2_HOME when it was set explicitly.
+func pm2Home(daemon, target Process) string {
+	title := daemon.GetCmdline()
+	if i, j := strings.LastIndex(title, "("), strings.LastIndex(title, ")"); i != -1 && j > i {
+		return title[i+1 : j]
+	}
+	if home := envValue(target, "PM2_HOME"); home != "" {
+		return home
+	}
+	if _, home := lookupUser(daemon.GetUser()); home != "" {
+		return home + "/.pm2"
+	}
+	return ""
+}
+
+// pm2StartupScript finds the boot script `pm2 startup` installs for user.
+func pm2StartupScript(user string) string {
+	candidates := []string{
+		"/etc/systemd/system/pm2-" + user + ".service",
+		"/etc/init.d/pm2-" + user,
+		"/etc/rc.d/pm2-" + user,
+		"/usr/local/etc/rc.d/pm2_" + user,
+	}
+	if _, home := lookupUser(user); home != "" {
+		candidates = append(candidates, home+"/Library/LaunchAgents/pm2."+user+".plist")
+	}
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// pm2Details identifies the app a PM2 daemon runs from the pm_id and name
+// variables PM2 injects into each app, filling gaps from dump.pm2.
+func pm2Details(src *Source, ancestry []Process, i int) {
+	daemon, target := ancestry[i], ancestry[len(ancestry)-1]
+	details := make(map[string]string)
+	home := pm2Home(daemon, target)
+	if home != "" {
+		details["pm2_home"] = home
+	}
+
+	var app Process
+	for j := len(ancestry) - 1; j >= i; j-- {
+		if envValue(ancestry[j], "pm_id") != "" {
+			app = ancestry[j]
+			break
+		}
+	}
+	if app == nil {
+		src.Details = details
+		return
+	}
+	id, name := envValue(app, "pm_id"), envValue(app, "name")
+	details["pm2_id"] = id
+	details["pm2_app"] = name
+	for key, env := range map[string]string{
+		"script":    "pm_exec_path",
+		"restarts":  "restart_time",
+		"watch":     "watch",
+		"exec_mode": "exec_mode",
+	} {
+		if v := envValue(app, env); v != "" {
+			details[key] = v
+		}
+	}
+	src.Name = "pm2/" + name
+	src.Evidence = append(src.Evidence, "environment of pid "+itoa(app.GetPID())+" sets pm_id="+id+", name="+name)
+
+	// Saved apps are the ones `pm2 resurrect` restarts at boot; without
+	// PM2_HOME there is no dump.pm2 to look in
+	var saved *pm2App
+	var apps []pm2App
+	if home != "" {
+		if data, err := os.ReadFile(home + "/dump.pm2"); err == nil && json.Unmarshal(data, &apps) == nil {
+			for k := range apps {
+				if apps[k].Name == name {
+					saved = &apps[k]
+					break
+				}
+			}
+		}
+	}
+	if saved != nil {
+		for key, v := range map[string]string{
+			"script":    saved.ExecPath,
+			"restarts":  itoa(saved.RestartTime),
+			"exec_mode": saved.ExecMode,
+			"watch":     fmt.Sprint(saved.Watch),
+		} {
+			if details[key] == "" && v != "" && v != "<nil>" {
+				details[key] = v
+			}
+		}
+	}
+	details["exec_mode"] = strings.TrimSuffix(details["exec_mode"], "_mode")
+
+	desc := "PM2 app " + name + " (id " + id
+	if mode := details["exec_mode"]; mode != "" {
+		desc += ", " + mode + " mode"
+	}
+	desc += ")"
+	startup := pm2StartupScript(daemon.GetUser())
+	switch {
+	case startup != "" && home == "":
+		details["startup"] = startup
+		desc += ", pm2 startup is installed but its dump.pm2 was not found"
+	case startup != "" && saved != nil:
+		details["startup"] = startup
+		details["resurrect"] = "yes"
+		desc += ", resurrected at boot by " + startup
+	case startup != "":
+		details["startup"] = startup
+		details["resurrect"] = "no, not in dump.pm2 (run pm2 save)"
+		desc += ", not saved, so pm2 startup will not bring it back"
+	case saved != nil:
+		details["resurrect"] = "no, pm2 startup is not installed"
+		desc += ", saved but no pm2 startup script is installed"
+	default:
+		details["resurrect"] = "no"
+		desc += ", not restored after a reboot"
+	}
+	src.Description = desc
+	src.Details = details
+}
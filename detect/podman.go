@@ -0,0 +1,165 @@
+package detect
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// Podman storage roots for rootful containers. Rootless containers live
+// under the owning user's home and runtime directory instead.
+var (
+	podmanGraphRoot = "/var/lib/containers/storage"
+	podmanRunRoot   = "/run/containers/storage"
+)
+
+// podmanContainer identifies a podman container from its cgroup path.
+type podmanContainer struct {
+	ID      string
+	PodID   string
+	PodName string
+	UID     string // owning user for rootless containers
+}
+
+// parsePodman finds a libpod container in any line of /proc/<pid>/cgroup:
+//
+//	/machine.slice/libpod-<id>.scope
+//	/machine.slice/machine-libpod_pod_<pod>.slice/libpod-<id>.scope
+//	/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-<id>.scope
+//	/system.slice/web.service/libpod-payload-<id>   (--cgroups=split, quadlet)
+func parsePodman(data string) *podmanContainer {
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 3 || !strings.Contains(parts[2], "libpod") {
+			continue
+		}
+		c := &podmanContainer{}
+		for _, seg := range strings.Split(parts[2], "/") {
+			switch {
+			case strings.HasPrefix(seg, "libpod-conmon-"):
+				// conmon's own scope, not the container
+			case strings.HasPrefix(seg, "libpod-"):
+				id := strings.TrimSuffix(strings.TrimPrefix(seg, "libpod-"), ".scope")
+				if id = strings.TrimPrefix(id, "payload-"); isHexID(id) {
+					c.ID = id
+				}
+			case strings.Contains(seg, "libpod_pod_"):
+				c.PodID = strings.TrimSuffix(seg[strings.Index(seg, "libpod_pod_")+len("libpod_pod_"):], ".slice")
+			case strings.HasPrefix(seg, "user@") && strings.HasSuffix(seg, ".service"):
+				c.UID = strings.TrimSuffix(strings.TrimPrefix(seg, "user@"), ".service")
+			}
+		}
+		if c.ID != "" {
+			return c
+		}
+	}
+	return nil
+}
+
+// storageRoots returns the graph and run roots holding the container.
+func (c *podmanContainer) storageRoots() (graph, run string) {
+	if c.UID == "" {
+		return podmanGraphRoot, podmanRunRoot
+	}
+	_, home := lookupUser(c.UID)
+	if home == "" {
+		return "", ""
+	}
+	return home + "/.local/share/containers/storage", "/run/user/" + c.UID + "/containers"
+}
+
+// podmanStorageEntry mirrors an entry of overlay-containers/containers.json.
+type podmanStorageEntry struct {
+	ID       string   `json:"id"`
+	Names    []string `json:"names"`
+	Image    string   `json:"image"`
+	Metadata string   `json:"metadata"`
+	Created  string   `json:"created"`
+}
+
+// info reads the container's name and image from containers/storage.
+func (c *podmanContainer) info() *containerInfo {
+	info := &containerInfo{Runtime: "podman", ID: c.ID}
+	graph, run := c.storageRoots()
+	if graph == "" {
+		return info
+	}
+	data, err := os.ReadFile(graph + "/overlay-containers/containers.json")
+	if err != nil {
+		return info
+	}
+	var entries []podmanStorageEntry
+	if json.Unmarshal(data, &entries) != nil {
+		return info
+	}
+	for _, e := range entries {
+		if e.ID != c.ID {
+			continue
+		}
+		if len(e.Names) > 0 {
+			info.Name = e.Names[0]
+		}
+		info.Created = e.Created
+		info.Image = e.Image
+		var meta struct {
+			ImageName string `json:"image-name"`
+		}
+		if json.Unmarshal([]byte(e.Metadata), &meta) == nil && meta.ImageName != "" {
+			info.Image = meta.ImageName
+		}
+		break
+	}
+	if c.PodID != "" {
+		// Containers in a pod share its UTS namespace, named after the pod
+		for _, root := range []string{run, graph} {
+			if data, err := os.ReadFile(root + "/overlay-containers/" + c.ID + "/userdata/hostname"); err == nil {
+				c.PodName = strings.TrimSpace(string(data))
+				break
+			}
+		}
+	}
+	return info
+}
+
+// source describes the podman container, including the quadlet unit that
+// generated it when the container runs under systemd.
+func (c *podmanContainer) source(ancestry []Process) *Source {
+	info := c.info()
+	details := info.details()
+	desc := "Podman container " + info.sourceName()
+	if c.UID != "" {
+		details["rootless"] = "true"
+		details["uid"] = c.UID
+		user, _ := lookupUser(c.UID)
+		if user == "" {
+			user = c.UID
+		}
+		desc += " (rootless, user " + user + ")"
+	}
+	if c.PodID != "" {
+		details["pod_id"] = c.PodID
+		pod := shortID(c.PodID)
+		if c.PodName != "" {
+			details["pod_name"] = c.PodName
+			pod = c.PodName
+		}
+		desc += ", in pod " + pod
+	}
+
+	// The unit owning conmon (or the payload) is the one systemd restarts
+	for i := len(ancestry) - 1; i >= 0; i-- {
+		unit := ancestry[i].GetService()
+		if !strings.HasSuffix(unit, ".service") || strings.HasPrefix(unit, "user@") {
+			continue
+		}
+		details["systemd_unit"] = unit
+		if path := quadletSource(unit, c.UID); path != "" {
+			details["quadlet_source"] = path
+			desc += ", generated by quadlet from " + path
+		} else {
+			desc += ", run by " + unit
+		}
+		break
+	}
+	return &Source{Type: SourceContainer, Name: info.sourceName(), Confidence: 0.95, Description: desc, Details: details}
+}
@@ -0,0 +1,110 @@
+package detect
+
+import "sort"
+
+// Detector identifies one kind of source from a process ancestry,
+// ordered root to target. Detect returns nil when it does not apply.
+type Detector interface {
+	Name() string
+	Detect(ancestry []Process) *Source
+}
+
+// DetectorFunc adapts a plain function to the Detector interface.
+type DetectorFunc struct {
+	ID string
+	Fn func(ancestry []Process) *Source
+}
+
+func (d DetectorFunc) Name() string                      { return d.ID }
+func (d DetectorFunc) Detect(ancestry []Process) *Source { return d.Fn(ancestry) }
+
+// Priorities of the built-in detectors. Lower runs first and the first
+// match becomes the primary source; the gaps leave room for others.
+const (
+	PriorityRules       = 50
+	PriorityContainer   = 100
+	PrioritySandbox     = 200
+	PriorityCI          = 250
+	PrioritySupervisor  = 300
+	PriorityCron        = 400
+	PriorityIDE         = 440
+	PriorityMultiplexer = 450
+	PriorityDetached    = 470
+	PriorityShell       = 500
+	PriorityInit        = 600
+)
+
+type registration struct {
+	priority int
+	detector Detector
+}
+
+var registry []registration
+
+func init() {
+	Register(PriorityRules, DetectorFunc{"rules", detectRules})
+	Register(PriorityContainer, DetectorFunc{"container", detectContainer})
+	Register(PrioritySandbox, DetectorFunc{"sandbox", detectSandbox})
+	Register(PriorityCI, DetectorFunc{"ci", detectCI})
+	Register(PrioritySupervisor, DetectorFunc{"supervisor", detectSupervisor})
+	Register(PriorityCron, DetectorFunc{"cron", detectCron})
+	Register(PriorityIDE, DetectorFunc{"ide", detectIDE})
+	Register(PriorityMultiplexer, DetectorFunc{"multiplexer", detectMultiplexer})
+	Register(PriorityDetached, DetectorFunc{"detached", detectDetached})
+	Register(PriorityShell, DetectorFunc{"shell", detectShell})
+	Register(PriorityInit, DetectorFunc{"init", detectInit})
+}
+
+// Register adds a detector at the given priority. Detectors with equal
+// priority run in registration order. Register is meant to be called
+// during initialisation and is not safe to use concurrently with Detect.
+func Register(priority int, d Detector) {
+	registry = append(registry, registration{priority, d})
+	sort.SliceStable(registry, func(i, j int) bool {
+		return registry[i].priority < registry[j].priority
+	})
+}
+
+// Verdict is one detector's answer for an ancestry.
+type Verdict struct {
+	Detector string
+	Priority int
+	Source   *Source // nil when the detector did not match
+	Selected bool    // the first match, reported by Detect
+}
+
+// Explain runs every registered detector, not just up to the first match,
+// so callers can see why a source was or was not picked.
+func Explain(ancestry []Process) []Verdict {
+	verdicts := make([]Verdict, 0, len(registry))
+	selected := false
+	for _, r := range registry {
+		v := Verdict{Detector: r.detector.Name(), Priority: r.priority, Source: r.detector.Detect(ancestry)}
+		if v.Source != nil && !selected {
+			v.Selected, selected = true, true
+		}
+		verdicts = append(verdicts, v)
+	}
+	return verdicts
+}
+
+// Primary returns the source Detect would have picked from verdicts, so
+// callers that explain detection need not run it twice.
+func Primary(verdicts []Verdict) Source {
+	for _, v := range verdicts {
+		if v.Selected {
+			return *v.Source
+		}
+	}
+	return unknownSource()
+}
+
+// detectorNamed returns the registered detector called name.
+func detectorNamed(name string) Detector {
+	for _, r := range registry {
+		if r.detector.Name() == name {
+			return r.detector
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package detect
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// rule maps processes matching every set field to a source. Rules come
+// from rules.json and are checked before any built-in detector:
+//
+//	{"rules": [
+//	  {"comm": "ourco-runner", "type": "supervisor", "name": "ourco-runner", "confidence": 0.9},
+//	  {"cmdline": "^python3 -m ourco\\.wrap\\b", "type": "supervisor", "name": "ourco-wrap"},
+//	  {"env": "OURCO_JOB_ID", "type": "cron", "name": "ourco-scheduler"}
+//	]}
+type rule struct {
+	Comm    string `json:"comm,omitempty"`    // exact command name
+	Cmdline string `json:"cmdline,omitempty"` // regular expression
+	Exe     string `json:"exe,omitempty"`     // executable path or glob
+	Cgroup  string `json:"cgroup,omitempty"`  // substring of the cgroup path
+	Env     string `json:"env,omitempty"`     // NAME, or NAME=value
+
+	Type       SourceType `json:"type"`
+	Name       string     `json:"name,omitempty"`       // defaults to the process comm
+	Confidence float64    `json:"confidence,omitempty"` // defaults to 0.8
+
+	file    string
+	cmdline *regexp.Regexp
+}
+
+// rulesFiles returns the rule files in precedence order: the user's
+// before the system-wide one.
+func rulesFiles() []string {
+	var files []string
+	config := os.Getenv("XDG_CONFIG_HOME")
+	if config == "" {
+		if home, err := os.UserHomeDir(); err == nil {
+			config = home + "/.config"
+		}
+	}
+	if config != "" {
+		files = append(files, config+"/witr/rules.json")
+	}
+	return append(files, "/etc/witr/rules.json")
+}
+
+var (
+	rulesOnce   sync.Once
+	loadedRules []rule
+	rulesErrors []string
+)
+
+// rules loads the rule files once. Problems are kept for Warnings rather
+// than failing detection.
+func rules() []rule {
+	rulesOnce.Do(func() {
+		for _, path := range rulesFiles() {
+			rs, err := loadRules(path)
+			if err != nil {
+				if !os.IsNotExist(err) {
+					rulesErrors = append(rulesErrors, "Ignoring rules file "+path+": "+err.Error())
+				}
+				continue
+			}
+			loadedRules = append(loadedRules, rs...)
+		}
+	})
+	return loadedRules
+}
+
+// loadRules parses and validates one rules file.
+func loadRules(path string) ([]rule, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Rules []rule `json:"rules"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	for i := range file.Rules {
+		r := &file.Rules[i]
+		r.file = path
+		if r.Type == "" {
+			return nil, fmt.Errorf("rule %d: missing type", i+1)
+		}
+		if r.Comm == "" && r.Cmdline == "" && r.Exe == "" && r.Cgroup == "" && r.Env == "" {
+			return nil, fmt.Errorf("rule %d: no comm, cmdline, exe, cgroup or env to match", i+1)
+		}
+		if r.Cmdline != "" {
+			if r.cmdline, err = regexp.Compile(r.Cmdline); err != nil {
+				return nil, fmt.Errorf("rule %d: %v", i+1, err)
+			}
+		}
+		if r.Exe != "" {
+			if _, err := filepath.Match(r.Exe, ""); err != nil {
+				return nil, fmt.Errorf("rule %d: exe: %v", i+1, err)
+			}
+		}
+		if r.Confidence == 0 {
+			r.Confidence = 0.8
+		}
+	}
+	return file.Rules, nil
+}
+
+// match reports whether p satisfies every matcher the rule sets, and
+// describes what matched.
+func (r *rule) match(p Process) (string, bool) {
+	var matched []string
+	if r.Comm != "" {
+		if p.GetCommand() != r.Comm {
+			return "", false
+		}
+		matched = append(matched, "comm="+r.Comm)
+	}
+	if r.cmdline != nil {
+		if !r.cmdline.MatchString(p.GetCmdline()) {
+			return "", false
+		}
+		matched = append(matched, "cmdline~"+r.Cmdline)
+	}
+	if r.Exe != "" {
+		exe := readExe(p.GetPID())
+		if ok, _ := filepath.Match(r.Exe, exe); !ok || exe == "" {
+			return "", false
+		}
+		matched = append(matched, "exe="+exe)
+	}
+	if r.Cgroup != "" {
+		data, err := os.ReadFile("/proc/" + itoa(p.GetPID()) + "/cgroup")
+		if err != nil || !strings.Contains(string(data), r.Cgroup) {
+			return "", false
+		}
+		matched = append(matched, "cgroup contains "+r.Cgroup)
+	}
+	if r.Env != "" {
+		key, want, hasValue := strings.Cut(r.Env, "=")
+		val, set := "", false
+		for _, e := range p.GetEnv() {
+			if k, v, _ := strings.Cut(e, "="); k == key {
+				val, set = v, true
+				break
+			}
+		}
+		if !set || (hasValue && val != want) {
+			return "", false
+		}
+		matched = append(matched, "env "+r.Env)
+	}
+	return strings.Join(matched, ", "), true
+}
+
+// detectRules finds the process nearest the target that a rule matches.
+func detectRules(ancestry []Process) *Source {
+	for i := len(ancestry) - 1; i >= 0; i-- {
+		if src := matchRules(ancestry[i]); src != nil {
+			return src
+		}
+	}
+	return nil
+}
+
+// matchRules returns the source of the first rule p satisfies.
+func matchRules(p Process) *Source {
+	rs := rules()
+	for i := range rs {
+		r := &rs[i]
+		matched, ok := r.match(p)
+		if !ok {
+			continue
+		}
+		name := r.Name
+		if name == "" {
+			name = p.GetCommand()
+		}
+		return &Source{
+			Type:       r.Type,
+			Name:       name,
+			Confidence: r.Confidence,
+			PID:        p.GetPID(),
+			Details:    map[string]string{"rule_file": r.file},
+			Evidence:   []string{ancestorEvidence(p, "matches rule in "+r.file+" ("+matched+")")},
+		}
+	}
+	return nil
+}
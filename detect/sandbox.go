@@ -0,0 +1,304 @@
+package detect
+
+import (
+	"os"
+	"strings"
+)
+
+// snapMountDir is where snapd mounts installed snap revisions.
+var snapMountDir = "/snap"
+
+// snapPackage identifies a snap from a process's cgroup.
+type snapPackage struct {
+	Name     string
+	App      string
+	Revision string
+	Unit     string // snap.<name>.<app>.service for snapd-managed daemons
+	Evidence string
+}
+
+// parseSnapCgroup finds a snap unit in any line of /proc/<pid>/cgroup.
+// snapd runs daemons as services and launches apps in transient scopes:
+//
+//	/system.slice/snap.lxd.daemon.service
+//	/user.slice/user-1000.slice/user@1000.service/app.slice/snap.firefox.firefox-<uuid>.scope
+func parseSnapCgroup(data string) *snapPackage {
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		for _, seg := range strings.Split(parts[2], "/") {
+			if !strings.HasPrefix(seg, "snap.") {
+				continue
+			}
+			var rest string
+			var service bool
+			switch {
+			case strings.HasSuffix(seg, ".service"):
+				rest, service = strings.TrimSuffix(seg, ".service"), true
+			case strings.HasSuffix(seg, ".scope"):
+				rest = strings.TrimSuffix(seg, ".scope")
+			default:
+				continue
+			}
+			name, app, ok := strings.Cut(strings.TrimPrefix(rest, "snap."), ".")
+			if !ok || name == "" {
+				continue
+			}
+			s := &snapPackage{Name: name}
+			if service {
+				s.App, s.Unit = app, seg
+			} else {
+				// Scopes carry a UUID: <app>-<uuid> or, with older snapd, <app>.<uuid>
+				app, _, _ = strings.Cut(app, ".")
+				if n := len(app) - 37; n > 0 && app[n] == '-' {
+					app = app[:n]
+				}
+				s.App = app
+			}
+			return s
+		}
+	}
+	return nil
+}
+
+// parseSnapExe recognises an executable inside a mounted snap:
+// /snap/<name>/<revision>/...
+func parseSnapExe(exe string) *snapPackage {
+	rest, ok := strings.CutPrefix(exe, snapMountDir+"/")
+	if !ok {
+		return nil
+	}
+	parts := strings.SplitN(rest, "/", 3)
+	if len(parts) < 3 || parts[0] == "bin" {
+		return nil
+	}
+	return &snapPackage{Name: parts[0], Revision: parts[1]}
+}
+
+// findSnap identifies the snap p runs in from its cgroup, the one piece
+// of evidence a process cannot inherit from a snap that merely started
+// it. The exe path and SNAP_REVISION only fill in the revision.
+func findSnap(p Process) *snapPackage {
+	pid := itoa(p.GetPID())
+	data, err := os.ReadFile("/proc/" + pid + "/cgroup")
+	if err != nil {
+		return nil
+	}
+	s := parseSnapCgroup(string(data))
+	if s == nil {
+		return nil
+	}
+	s.Evidence = "cgroup of pid " + pid + " is in a snap." + s.Name + " unit"
+	if exe := parseSnapExe(readExe(p.GetPID())); exe != nil && exe.Name == s.Name {
+		s.Revision = exe.Revision
+	}
+	if s.Revision == "" {
+		s.Revision = envValue(p, "SNAP_REVISION")
+	}
+	if s.Revision == "" {
+		s.Revision, _ = os.Readlink(snapMountDir + "/" + s.Name + "/current")
+	}
+	return s
+}
+
+// meta reads the top-level keys of the snap's meta/snap.yaml, or returns
+// nil when the revision is unknown or the file unreadable.
+func (s *snapPackage) meta() map[string]string {
+	if s.Revision == "" {
+		return nil
+	}
+	data, err := os.ReadFile(snapMountDir + "/" + s.Name + "/" + s.Revision + "/meta/snap.yaml")
+	if err != nil {
+		return nil
+	}
+	meta := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" || line[0] == ' ' || line[0] == '#' {
+			continue
+		}
+		if key, val, ok := strings.Cut(line, ":"); ok {
+			if val = strings.Trim(strings.TrimSpace(val), `"'`); val != "" {
+				meta[key] = val
+			}
+		}
+	}
+	return meta
+}
+
+// source describes the snap. snapd services are reported as the snap
+// rather than as the generated systemd unit that runs them.
+func (s *snapPackage) source(confidence float64) *Source {
+	meta := s.meta()
+	// snap.yaml leaves out confinement for strict snaps; without the
+	// file it is unknown
+	confinement := meta["confinement"]
+	if confinement == "" && meta != nil {
+		confinement = "strict"
+	}
+	details := map[string]string{"snap_name": s.Name}
+	for k, v := range map[string]string{
+		"confinement":  confinement,
+		"snap_app":     s.App,
+		"revision":     s.Revision,
+		"version":      meta["version"],
+		"systemd_unit": s.Unit,
+	} {
+		if v != "" {
+			details[k] = v
+		}
+	}
+
+	// Snap commands are <name>.<app>, or just <name> for the main app
+	name := s.Name
+	if s.App != "" && s.App != s.Name {
+		name += "." + s.App
+	}
+	desc := "Snap " + name
+	if s.Unit != "" {
+		desc = "Snap service " + name + ", run by snapd as " + s.Unit
+	}
+	var notes []string
+	if s.Revision != "" {
+		notes = append(notes, "revision "+s.Revision)
+	}
+	if confinement != "" {
+		notes = append(notes, confinement+" confinement")
+	}
+	if len(notes) > 0 {
+		desc += " (" + strings.Join(notes, ", ") + ")"
+	}
+	return &Source{Type: SourceSnap, Name: name, Confidence: confidence, Description: desc, Details: details,
+		Evidence: []string{s.Evidence}}
+}
+
+// readFlatpakInfo parses the .flatpak-info keyfile flatpak places at the
+// root of every sandbox, keyed as "Section.key".
+func readFlatpakInfo(pid int) map[string]string {
+	data, err := os.ReadFile("/proc/" + itoa(pid) + "/root/.flatpak-info")
+	if err != nil {
+		return nil
+	}
+	info := make(map[string]string)
+	var section string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || line[0] == '#':
+		case line[0] == '[':
+			section = strings.Trim(line, "[]")
+		default:
+			if key, val, ok := strings.Cut(line, "="); ok {
+				info[section+"."+key] = val
+			}
+		}
+	}
+	return info
+}
+
+// parseFlatpakScope finds the scope flatpak launches apps in when the
+// sandbox root itself is unreadable: app-flatpak-<app-id>-<pid>.scope.
+func parseFlatpakScope(data string) string {
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) < 3 {
+			continue
+		}
+		for _, seg := range strings.Split(parts[2], "/") {
+			if id, ok := strings.CutPrefix(seg, "app-flatpak-"); ok && strings.HasSuffix(id, ".scope") {
+				if i := strings.LastIndexByte(id, '-'); i > 0 {
+					return unescapeUnit(id[:i])
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// flatpakSource describes the flatpak sandbox p runs in.
+func flatpakSource(p Process) *Source {
+	info := readFlatpakInfo(p.GetPID())
+	if info == nil {
+		data, err := os.ReadFile("/proc/" + itoa(p.GetPID()) + "/cgroup")
+		if err != nil {
+			return nil
+		}
+		app := parseFlatpakScope(string(data))
+		if app == "" {
+			return nil
+		}
+		return &Source{
+			Type:        SourceFlatpak,
+			Name:        app,
+			Confidence:  0.7,
+			Description: "Flatpak " + app + " (sandboxed)",
+			Details:     map[string]string{"flatpak_app": app, "confinement": "sandboxed"},
+			PID:         p.GetPID(),
+			Evidence:    []string{"cgroup of pid " + itoa(p.GetPID()) + " is in an app-flatpak scope"},
+		}
+	}
+
+	app := info["Application.name"]
+	if app == "" {
+		// Runtimes run directly, e.g. flatpak run --command=sh org.gnome.Platform
+		app = info["Runtime.runtime"]
+	}
+	details := map[string]string{"flatpak_app": app, "confinement": "sandboxed"}
+	for key, field := range map[string]string{
+		"branch":          "Instance.branch",
+		"arch":            "Instance.arch",
+		"commit":          "Instance.app-commit",
+		"instance_id":     "Instance.instance-id",
+		"flatpak_runtime": "Application.runtime",
+		"filesystems":     "Context.filesystems",
+		"shared":          "Context.shared",
+		"sockets":         "Context.sockets",
+	} {
+		if v := strings.TrimSuffix(info[field], ";"); v != "" {
+			details[key] = v
+		}
+	}
+	// filesystems=host means the sandbox sees the whole host filesystem
+	for _, fs := range strings.Split(details["filesystems"], ";") {
+		if fs == "host" || fs == "host-os" {
+			details["confinement"] = "sandboxed, host filesystem access"
+		}
+	}
+
+	desc := "Flatpak " + app + " ("
+	if b := details["branch"]; b != "" {
+		desc += "branch " + b + ", "
+	}
+	if rt := strings.TrimPrefix(details["flatpak_runtime"], "runtime/"); rt != "" {
+		desc += "runtime " + rt + ", "
+	}
+	desc += details["confinement"] + ")"
+	return &Source{Type: SourceFlatpak, Name: app, Confidence: 0.9, Description: desc, Details: details,
+		PID: p.GetPID(), Evidence: []string{"/proc/" + itoa(p.GetPID()) + "/root/.flatpak-info names " + app}}
+}
+
+// detectSandbox finds the snap or flatpak nearest to the target.
+func detectSandbox(ancestry []Process) *Source {
+	for i := len(ancestry) - 1; i >= 0; i-- {
+		p := ancestry[i]
+		if p.GetPID() == 1 {
+			break
+		}
+		if src := flatpakSource(p); src != nil {
+			return src
+		}
+	}
+	// Snap cgroups are inherited, so the target's own is enough and an
+	// ancestor's would only mean a snap launched it
+	if len(ancestry) == 0 {
+		return nil
+	}
+	target := ancestry[len(ancestry)-1]
+	if s := findSnap(target); s != nil {
+		src := s.source(0.9)
+		src.PID = target.GetPID()
+		return src
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+package detect
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// multiplexerComms maps a multiplexer server's comm to its name. tmux
+// renames its server "tmux: server"; screen's server runs as SCREEN.
+var multiplexerComms = map[string]string{
+	"tmux: server": "tmux",
+	"tmux":         "tmux",
+	"screen":       "screen",
+	"SCREEN":       "screen",
+	"zellij":       "zellij",
+}
+
+// detectMultiplexer finds a terminal multiplexer server in the ancestry.
+// Processes under one outlive the terminal and the login that started them.
+func detectMultiplexer(ancestry []Process) *Source {
+	for i := len(ancestry) - 1; i >= 0; i-- {
+		server := ancestry[i]
+		name, ok := multiplexerComms[server.GetCommand()]
+		if !ok {
+			continue
+		}
+		details := map[string]string{"multiplexer": name}
+		src := &Source{
+			Type:       SourceMultiplexer,
+			Name:       name,
+			Confidence: 0.85,
+			PID:        server.GetPID(),
+			Details:    details,
+			Evidence:   []string{ancestorEvidence(server, "comm="+server.GetCommand())},
+		}
+		// byobu is a tmux or screen profile, not a server of its own
+		if strings.Contains(server.GetCmdline(), "byobu") {
+			details["backend"] = name
+			src.Name = "byobu"
+		}
+
+		env := func(key string) string {
+			for j := len(ancestry) - 1; j > i; j-- {
+				if v := envValue(ancestry[j], key); v != "" {
+					return v
+				}
+			}
+			return ""
+		}
+		if src.Name != "byobu" && env("BYOBU_BACKEND") != "" {
+			details["backend"] = name
+			src.Name = "byobu"
+		}
+		switch name {
+		case "tmux":
+			if v := env("TMUX"); v != "" {
+				// TMUX=<socket>,<server pid>,<session id>; asking the
+				// server for names would mean running tmux itself
+				f := strings.Split(v, ",")
+				details["socket"] = f[0]
+				if len(f) == 3 {
+					details["session"] = "$" + f[2]
+				}
+				details["pane"] = env("TMUX_PANE")
+				src.Evidence = append(src.Evidence, "environment sets TMUX="+v)
+			}
+		case "screen":
+			if sty := env("STY"); sty != "" {
+				// STY=<server pid>.<session name>
+				_, session, _ := strings.Cut(sty, ".")
+				details["session"] = session
+				details["window"] = env("WINDOW")
+				src.Evidence = append(src.Evidence, "environment sets STY="+sty)
+			}
+		case "zellij":
+			if session := env("ZELLIJ_SESSION_NAME"); session != "" {
+				details["session"] = session
+				src.Evidence = append(src.Evidence, "environment sets ZELLIJ_SESSION_NAME="+session)
+			}
+		}
+
+		desc := "Running in " + src.Name
+		if s := details["session"]; s != "" {
+			desc += " session " + s
+		}
+		if w := details["window"]; w != "" {
+			desc += ", window " + w
+		}
+		src.Description = desc + "; survives logout while the " + name + " server runs"
+		for k, v := range details {
+			if v == "" {
+				delete(details, k)
+			}
+		}
+		return src
+	}
+	return nil
+}
+
+// procStat holds the /proc/<pid>/stat fields that describe a process's
+// session and terminal.
+type procStat struct {
+	PPID, Session, TTY int
+}
+
+func readProcStat(pid int) *procStat {
+	data, err := os.ReadFile("/proc/" + itoa(pid) + "/stat")
+	if err != nil {
+		return nil
+	}
+	// comm may contain spaces and parentheses; fields resume after the last ")"
+	s := string(data)
+	f := strings.Fields(s[strings.LastIndexByte(s, ')')+1:])
+	if len(f) < 5 {
+		return nil
+	}
+	st := &procStat{}
+	st.PPID, _ = strconv.Atoi(f[1])
+	st.Session, _ = strconv.Atoi(f[3])
+	st.TTY, _ = strconv.Atoi(f[4])
+	return st
+}
+
+// ignoresSIGHUP reports whether SIGHUP (bit 0 of SigIgn) is ignored, as
+// nohup arranges before exec'ing its command.
+func ignoresSIGHUP(pid int) bool {
+	data, err := os.ReadFile("/proc/" + itoa(pid) + "/status")
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "SigIgn:"); ok {
+			mask, err := strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+			return err == nil && mask&1 != 0
+		}
+	}
+	return false
+}
+
+// nohupOutput reports whether dir holds the nohup.out nohup writes to
+// when its output was a terminal.
+func nohupOutput(dir string) bool {
+	if dir == "" {
+		return false
+	}
+	_, err := os.Stat(dir + "/nohup.out")
+	return err == nil
+}
+
+// detectDetached recognises processes cut loose from their terminal with
+// nohup or setsid. Both exec the command, so the evidence is on the
+// process itself: nohup leaves SIGHUP ignored (or output in nohup.out
+// once the shell is gone), and setsid leaves an orphan adopted by PID 1
+// that leads its own session with no controlling terminal. Processes in
+// a .service unit, multiplexer servers and shells without that evidence
+// are left to the other detectors.
+func detectDetached(ancestry []Process) *Source {
+	for i := len(ancestry) - 1; i > 0; i-- {
+		p, parent := ancestry[i], ancestry[i-1]
+		pid := p.GetPID()
+		if strings.HasSuffix(p.GetService(), ".service") {
+			return nil
+		}
+		if _, ok := multiplexerComms[p.GetCommand()]; ok {
+			return nil
+		}
+		st := readProcStat(pid)
+		leader := st != nil && st.Session == pid && st.TTY == 0
+		orphan := parent.GetPID() == 1
+		hup := ignoresSIGHUP(pid)
+
+		var method string
+		var evidence []string
+		switch {
+		case hup:
+			method = "nohup"
+			evidence = append(evidence, "SigIgn of pid "+itoa(pid)+" has SIGHUP set")
+			if leader {
+				// setsid nohup cmd, or nohup setsid cmd
+				evidence = append(evidence, "pid "+itoa(pid)+" leads its own session with no controlling terminal")
+			}
+		case leader && (orphan || parent.GetCommand() == "setsid"):
+			// setsid -w keeps the forking parent around; otherwise it
+			// exits at once and PID 1 adopts the command
+			method = "setsid"
+			evidence = append(evidence, "pid "+itoa(pid)+" leads its own session with no controlling terminal")
+			if orphan {
+				evidence = append(evidence, "pid "+itoa(pid)+" was orphaned and adopted by pid 1")
+			} else {
+				evidence = append(evidence, ancestorEvidence(parent, "comm=setsid"))
+			}
+		case orphan && nohupOutput(p.GetWorkingDir()):
+			method = "nohup"
+			evidence = append(evidence, p.GetWorkingDir()+"/nohup.out exists",
+				"pid "+itoa(pid)+" was orphaned and adopted by pid 1")
+		case shells[p.GetCommand()]:
+			return nil
+		default:
+			continue
+		}
+
+		details := map[string]string{"method": method}
+		if hup {
+			details["sighup"] = "ignored"
+		}
+		if leader {
+			details["session_leader"] = "true"
+		}
+		desc := "Started with nohup: ignores SIGHUP, so it survives its terminal closing"
+		if method == "nohup" && !hup {
+			desc = "Started with nohup: writes to nohup.out and outlived its terminal"
+		} else if method == "setsid" {
+			desc = "Detached with setsid: leads its own session with no controlling terminal"
+		}
+		src := &Source{
+			Type:        SourceDetached,
+			Name:        method,
+			Confidence:  0.6,
+			PID:         pid,
+			Description: desc,
+			Details:     details,
+			Evidence:    evidence,
+		}
+		addLoginSession(src, ancestry[len(ancestry)-1])
+		return src
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// shimInfo describes the container shim found in a process's ancestry.
+type shimInfo struct {
+	PID         int
+	Kind        string // containerd-shim-runc-v2, conmon, ...
+	Runtime     string // containerd, cri-o or podman
+	Namespace   string // containerd namespace: moby, k8s.io, default
+	ContainerID string
+	SandboxID   string // pod sandbox a k8s.io shim serves
+	Name        string
+}
+
+// parseShim recognises containerd shims and conmon from their command
+// lines and pulls out the namespace and container ID:
+//
+//	containerd-shim-runc-v2 -namespace k8s.io -id <id> -address /run/containerd/containerd.sock
+//	conmon -b <bundle> -c <id> -n <name> -r /usr/bin/runc --exit-dir /var/run/crio/exits ...
+//
+// In the k8s.io namespace one shim serves a whole pod and its -id is the
+// pod sandbox, not the container; that comes from the cgroup instead.
+func parseShim(pid int, cmdline string) *shimInfo {
+	args := strings.Fields(cmdline)
+	if len(args) == 0 {
+		return nil
+	}
+	kind := filepath.Base(args[0])
+	s := &shimInfo{PID: pid, Kind: kind}
+	switch {
+	case strings.HasPrefix(kind, "containerd-shim"):
+		s.Runtime = "containerd"
+	case kind == "conmon":
+		s.Runtime = "podman"
+		if strings.Contains(cmdline, "crio") {
+			s.Runtime = "cri-o"
+		}
+	default:
+		return nil
+	}
+
+	var id string
+	for i := 1; i < len(args); i++ {
+		key, val, ok := strings.Cut(args[i], "=")
+		if !ok && i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+			i++
+			val = args[i]
+		}
+		switch strings.TrimLeft(key, "-") {
+		case "namespace":
+			s.Namespace = val
+		case "id", "c", "cid":
+			if id == "" {
+				id = val
+			}
+		case "n", "name":
+			s.Name = val
+		}
+	}
+	if s.Namespace == "k8s.io" {
+		s.SandboxID = id
+	} else {
+		s.ContainerID = id
+	}
+	return s
+}
+
+// containerID returns the container the shim runs target in: the one on
+// its command line, or for pod shims the one in target's cgroup path.
+func (s *shimInfo) containerID(target Process) string {
+	if s.ContainerID != "" || s.SandboxID == "" {
+		return s.ContainerID
+	}
+	data, err := os.ReadFile("/proc/" + itoa(target.GetPID()) + "/cgroup")
+	if err != nil {
+		return ""
+	}
+	if pod := parseKubePod(string(data)); pod != nil {
+		return pod.ContainerID
+	}
+	return ""
+}
+
+// findShim returns the shim nearest to the target in the ancestry.
+func findShim(ancestry []Process) *shimInfo {
+	for i := len(ancestry) - 1; i >= 0; i-- {
+		p := ancestry[i]
+		if s := parseShim(p.GetPID(), p.GetCmdline()); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// source describes the container a shim runs when its cgroup gave no
+// answer, e.g. with nested or unreadable cgroups.
+func (s *shimInfo) source() *Source {
+	if s.SandboxID != "" {
+		return &Source{
+			Type:       SourceKubernetes,
+			Name:       "pod sandbox " + shortID(s.SandboxID),
+			Confidence: 0.6,
+			Details:    map[string]string{"runtime": s.Runtime, "sandbox_id": s.SandboxID},
+		}
+	}
+	if s.ContainerID == "" {
+		return nil
+	}
+	var info *containerInfo
+	switch {
+	case s.Namespace == "moby":
+		info = inspectContainer("docker", s.ContainerID)
+		if src := composeSource(info); src != nil {
+			return src
+		}
+	case s.Runtime == "podman":
+		return (&podmanContainer{ID: s.ContainerID}).source(nil)
+	case s.Namespace == "k8s.io" || s.Runtime == "cri-o":
+		return &Source{
+			Type:       SourceKubernetes,
+			Name:       "container " + shortID(s.ContainerID),
+			Confidence: 0.7,
+			Details:    map[string]string{"runtime": s.Runtime, "container_id": s.ContainerID},
+		}
+	default:
+		info = &containerInfo{Runtime: s.Runtime, ID: s.ContainerID, Name: s.Name}
+	}
+	return &Source{Type: SourceContainer, Name: info.sourceName(), Confidence: 0.8, Details: info.details()}
+}
+
+// chain renders the runtime path to the target, e.g.
+// "containerd (ns k8s.io) → containerd-shim-runc-v2 (pid 812) → sandbox 9c1d2e3f4a5b → container 3f2a1b9c0d1e → nginx (pid 950)".
+func (s *shimInfo) chain(target Process) string {
+	runtime := s.Runtime
+	if s.Namespace != "" {
+		runtime += " (ns " + s.Namespace + ")"
+	}
+	parts := []string{runtime, s.Kind + " (pid " + itoa(s.PID) + ")"}
+	if s.SandboxID != "" {
+		parts = append(parts, "sandbox "+shortID(s.SandboxID))
+	}
+	if id := s.containerID(target); id != "" {
+		parts = append(parts, "container "+shortID(id))
+	}
+	parts = append(parts, target.GetCommand()+" (pid "+itoa(target.GetPID())+")")
+	return strings.Join(parts, " → ")
+}
+
+// annotate records the shim hop on a container source.
+func (s *shimInfo) annotate(src *Source, target Process) {
+	if src.Details == nil {
+		src.Details = make(map[string]string)
+	}
+	src.Details["shim"] = s.Kind
+	src.Details["shim_pid"] = itoa(s.PID)
+	if s.Namespace != "" {
+		src.Details["containerd_namespace"] = s.Namespace
+	}
+	if s.SandboxID != "" {
+		src.Details["sandbox_id"] = s.SandboxID
+	}
+	if id := s.containerID(target); src.Details["container_id"] == "" && id != "" {
+		src.Details["container_id"] = id
+	}
+	src.Details["runtime_chain"] = s.chain(target)
+	observation := "is " + s.Kind
+	if s.Namespace != "" {
+		observation += " (namespace " + s.Namespace + ")"
+	}
+	src.Evidence = append(src.Evidence, "ancestor pid "+itoa(s.PID)+" "+observation)
+}
@@ -0,0 +1,10 @@
+package detect
+
+// SocketActivation describes a .socket unit systemd listens on for a
+// service that is started on demand.
+type SocketActivation struct {
+	Unit    string // e.g. cups.socket
+	Listen  string // e.g. ListenStream=631
+	Service string // the unit started on connection
+	Accept  bool   // one service instance per connection
+}
@@ -0,0 +1,124 @@
+//go:build linux
+
+package detect
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// listenKeys are the [Socket] settings that bind network ports.
+var listenKeys = []string{"ListenStream", "ListenDatagram", "ListenSequentialPacket"}
+
+// socketService returns the unit a socket activates: Service=, else the
+// service of the same name (a foo@.service template with Accept=yes).
+func socketService(name string, u *unitFile) string {
+	if s := u.get("Socket", "Service"); s != "" {
+		return s
+	}
+	base := strings.TrimSuffix(name, ".socket")
+	if socketAccepts(u) {
+		return base + "@.service"
+	}
+	return base + ".service"
+}
+
+func socketAccepts(u *unitFile) bool {
+	switch strings.ToLower(u.get("Socket", "Accept")) {
+	case "yes", "true", "on", "1":
+		return true
+	}
+	return false
+}
+
+// socketListens lists a socket's listen settings as "Key=value".
+func socketListens(u *unitFile) []string {
+	var listens []string
+	for _, key := range listenKeys {
+		for _, v := range u.all("Socket", key) {
+			listens = append(listens, key+"="+v)
+		}
+	}
+	return listens
+}
+
+// listensOn reports whether a Listen*= value binds port: "631",
+// "0.0.0.0:631" or "[::]:631". Unix socket paths never match.
+func listensOn(value string, port int) bool {
+	p := strconv.Itoa(port)
+	if value == p {
+		return true
+	}
+	return !strings.HasPrefix(value, "/") && !strings.HasPrefix(value, "@") && strings.HasSuffix(value, ":"+p)
+}
+
+// unitSocket finds the .socket unit that activates service, including
+// per-connection instances (foo@3-10.0.0.1:22-10.0.0.9:51234.service).
+func unitSocket(service string, dirs []string) (string, *unitFile) {
+	base := strings.TrimSuffix(service, ".service")
+	candidates := []string{base + ".socket"}
+	if at := strings.Index(base, "@"); at != -1 {
+		candidates = append(candidates, base[:at]+".socket")
+	}
+	names := unitNames(service)
+	template := names[len(names)-1]
+	for _, n := range candidates {
+		if u := loadUnit(n, dirs); u != nil && !u.Masked {
+			if s := socketService(n, u); s == service || s == template {
+				return n, u
+			}
+		}
+	}
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(dir + "/*.socket")
+		for _, m := range matches {
+			n := filepath.Base(m)
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			if u := loadUnit(n, dirs); u != nil && !u.Masked && u.get("Socket", "Service") == service {
+				return n, u
+			}
+		}
+	}
+	return "", nil
+}
+
+// FindSocketUnit returns the system .socket unit listening on port, for
+// ports held by PID 1 itself. Enabled sockets win over disabled ones.
+func FindSocketUnit(port int) *SocketActivation {
+	var fallback *SocketActivation
+	seen := make(map[string]bool)
+	for _, dir := range systemUnitDirs {
+		matches, _ := filepath.Glob(dir + "/*.socket")
+		for _, m := range matches {
+			n := filepath.Base(m)
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			u := loadUnit(n, systemUnitDirs)
+			if u == nil || u.Masked {
+				continue
+			}
+			for _, key := range listenKeys {
+				for _, v := range u.all("Socket", key) {
+					if !listensOn(v, port) {
+						continue
+					}
+					sa := &SocketActivation{Unit: n, Listen: key + "=" + v, Service: socketService(n, u), Accept: socketAccepts(u)}
+					if state := unitEnablement(n, u, systemUnitDirs); state != "disabled" && state != "static" {
+						return sa
+					}
+					if fallback == nil {
+						fallback = sa
+					}
+				}
+			}
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,163 @@
+package detect
+
+import (
+	"encoding/hex"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sshSession parses the title sshd gives the process serving a login,
+// "sshd: alice@pts/3" (or "sshd-session: alice@pts/3" since OpenSSH 9.8,
+// "@notty" without a terminal). The privileged "[priv]" parent and the
+// listener do not match.
+func sshSession(p Process) (user, tty string, ok bool) {
+	title := p.GetCmdline()
+	for _, prefix := range []string{"sshd: ", "sshd-session: "} {
+		if rest, found := strings.CutPrefix(title, prefix); found {
+			user, tty, ok = strings.Cut(strings.TrimSpace(rest), "@")
+			return user, tty, ok && user != "" && !strings.Contains(tty, " ")
+		}
+	}
+	return "", "", false
+}
+
+// sshPeer returns the client address of the established TCP connection
+// held by an sshd session process, for shells whose environment is not
+// readable.
+func sshPeer(pid int) (ip string, port int) {
+	inodes := make(map[string]bool)
+	fdPath := "/proc/" + itoa(pid) + "/fd"
+	fds, _ := os.ReadDir(fdPath)
+	for _, fd := range fds {
+		link, _ := os.Readlink(fdPath + "/" + fd.Name())
+		if inode, ok := strings.CutPrefix(link, "socket:["); ok {
+			inodes[strings.TrimSuffix(inode, "]")] = true
+		}
+	}
+	for _, name := range []string{"tcp", "tcp6"} {
+		data, err := os.ReadFile("/proc/" + itoa(pid) + "/net/" + name)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 10 || fields[3] != "01" || !inodes[fields[9]] { // 01 = ESTABLISHED
+				continue
+			}
+			if ip, port := parseHexAddr(fields[2]); ip != "" {
+				return ip, port
+			}
+		}
+	}
+	return "", 0
+}
+
+// parseHexAddr decodes a /proc/net/tcp{,6} address, "0100007F:0016",
+// whose IP is stored as little-endian 32-bit words.
+func parseHexAddr(raw string) (string, int) {
+	addr, portHex, ok := strings.Cut(raw, ":")
+	b, err := hex.DecodeString(addr)
+	if !ok || err != nil || len(b)%4 != 0 {
+		return "", 0
+	}
+	for i := 0; i < len(b); i += 4 {
+		b[i], b[i+1], b[i+2], b[i+3] = b[i+3], b[i+2], b[i+1], b[i]
+	}
+	port, _ := strconv.ParseInt(portHex, 16, 32)
+	return net.IP(b).String(), int(port)
+}
+
+// privilegeHops lists the sudo and su invocations in the ancestry as
+// "sudo → root", naming the user each one switched to.
+func privilegeHops(ancestry []Process) []string {
+	var hops []string
+	for i, p := range ancestry {
+		cmd := p.GetCommand()
+		if (cmd == "sudo" || cmd == "su") && i+1 < len(ancestry) {
+			hops = append(hops, cmd+" → "+ancestry[i+1].GetUser())
+		}
+	}
+	return hops
+}
+
+// formatLoginTime shows the clock time for logins today and the date
+// otherwise.
+func formatLoginTime(t time.Time) string {
+	if t.Format("2006-01-02") == time.Now().Format("2006-01-02") {
+		return t.Format("15:04")
+	}
+	return t.Format("2006-01-02 15:04")
+}
+
+// sshDetails adds who logged in, from where and when to a shell source
+// whose ancestry passes through an sshd session, along with any sudo or
+// su hops on the way to the shell.
+func sshDetails(src *Source, ancestry []Process) {
+	details := make(map[string]string)
+	env := func(key string) string {
+		for i := len(ancestry) - 1; i >= 0; i-- {
+			if v := envValue(ancestry[i], key); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	if sudoUser := env("SUDO_USER"); sudoUser != "" {
+		details["sudo_user"] = sudoUser
+		src.Evidence = append(src.Evidence, "environment sets SUDO_USER="+sudoUser)
+	}
+	hops := privilegeHops(ancestry)
+	if len(hops) > 0 {
+		details["privilege_hops"] = strings.Join(hops, ", ")
+	}
+
+	for i, p := range ancestry {
+		user, tty, ok := sshSession(p)
+		if !ok {
+			continue
+		}
+		details["ssh_user"] = user
+		details["tty"] = tty
+		src.Evidence = append(src.Evidence, ancestorEvidence(p, "is sshd session "+user+"@"+tty))
+
+		// SSH_CONNECTION="client_ip client_port server_ip server_port";
+		// SSH_CLIENT is the older "client_ip client_port server_port"
+		if f := strings.Fields(env("SSH_CONNECTION")); len(f) == 4 {
+			details["ssh_client"], details["ssh_client_port"], details["ssh_server_port"] = f[0], f[1], f[3]
+			src.Evidence = append(src.Evidence, "environment sets SSH_CONNECTION")
+		} else if f := strings.Fields(env("SSH_CLIENT")); len(f) == 3 {
+			details["ssh_client"], details["ssh_client_port"], details["ssh_server_port"] = f[0], f[1], f[2]
+			src.Evidence = append(src.Evidence, "environment sets SSH_CLIENT")
+		} else if ip, port := sshPeer(p.GetPID()); ip != "" {
+			details["ssh_client"], details["ssh_client_port"] = ip, itoa(port)
+			src.Evidence = append(src.Evidence, "pid "+itoa(p.GetPID())+" holds a connection from "+ip)
+		}
+
+		desc := "Started interactively by " + user + " over SSH"
+		if ip := details["ssh_client"]; ip != "" {
+			desc += " from " + ip
+		}
+		if started := p.GetStartedAt(); !started.IsZero() {
+			details["login_time"] = started.Format("2006-01-02 15:04:05")
+			desc += " at " + formatLoginTime(started)
+		}
+		for _, hop := range privilegeHops(ancestry[i:]) {
+			desc += ", then " + hop
+		}
+		src.Description = desc
+		src.Confidence = 0.7
+		break
+	}
+	if src.Description == "" && details["sudo_user"] != "" {
+		src.Description = "Started interactively by " + details["sudo_user"]
+		for _, hop := range hops {
+			src.Description += ", then " + hop
+		}
+	}
+	if len(details) > 0 {
+		src.Details = details
+	}
+}
@@ -0,0 +1,179 @@
+package detect
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Config files supervisord searches when started without -c, after the
+// ones relative to its working directory.
+var supervisordConfigs = []string{
+	"/etc/supervisord.conf",
+	"/etc/supervisor/supervisord.conf",
+}
+
+// supervisorSection is one [section] of supervisord.conf or an include.
+type supervisorSection struct {
+	File   string
+	Values map[string]string
+}
+
+// supervisordConfigPath returns the config supervisord was started with:
+// its -c/--configuration argument, else the first default that exists.
+func supervisordConfigPath(p Process) string {
+	args := strings.Fields(p.GetCmdline())
+	dir := p.GetWorkingDir()
+	resolve := func(path string) string {
+		if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+		return path
+	}
+	for i, arg := range args {
+		switch {
+		case (arg == "-c" || arg == "--configuration") && i+1 < len(args):
+			return resolve(args[i+1])
+		case strings.HasPrefix(arg, "--configuration="):
+			return resolve(strings.TrimPrefix(arg, "--configuration="))
+		case strings.HasPrefix(arg, "-c") && len(arg) > 2:
+			return resolve(arg[2:])
+		}
+	}
+	var candidates []string
+	if dir != "" {
+		candidates = append(candidates, dir+"/supervisord.conf", dir+"/etc/supervisord.conf")
+	}
+	for _, path := range append(candidates, supervisordConfigs...) {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// loadSupervisordConfig parses a supervisord config and the files its
+// [include] section pulls in, keyed by section name, e.g. "program:web".
+func loadSupervisordConfig(path string) map[string]*supervisorSection {
+	sections := make(map[string]*supervisorSection)
+	seen := make(map[string]bool)
+	var load func(path string, depth int)
+	load = func(path string, depth int) {
+		if seen[path] || depth > 3 {
+			return
+		}
+		seen[path] = true
+		parsed := parseSupervisorINI(path)
+		for name, sec := range parsed {
+			if name != "include" {
+				sections[name] = sec
+			}
+		}
+		inc := parsed["include"]
+		if inc == nil {
+			return
+		}
+		here := filepath.Dir(path)
+		for _, pattern := range strings.Fields(inc.Values["files"]) {
+			pattern = strings.ReplaceAll(pattern, "%(here)s", here)
+			if !filepath.IsAbs(pattern) {
+				pattern = filepath.Join(here, pattern)
+			}
+			matches, _ := filepath.Glob(pattern)
+			for _, m := range matches {
+				load(m, depth+1)
+			}
+		}
+	}
+	load(path, 0)
+	return sections
+}
+
+// parseSupervisorINI reads supervisord's INI dialect: "key = value",
+// ";" or "#" comments, and indented continuation lines.
+func parseSupervisorINI(path string) map[string]*supervisorSection {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	sections := make(map[string]*supervisorSection)
+	var cur *supervisorSection
+	var lastKey string
+	for _, raw := range strings.Split(string(data), "\n") {
+		line := strings.TrimSpace(raw)
+		if line == "" || line[0] == ';' || line[0] == '#' {
+			continue
+		}
+		if cur != nil && lastKey != "" && (raw[0] == ' ' || raw[0] == '\t') {
+			cur.Values[lastKey] += " " + line
+			continue
+		}
+		if line[0] == '[' {
+			name := strings.TrimSpace(strings.Trim(line, "[]"))
+			cur = &supervisorSection{File: path, Values: make(map[string]string)}
+			sections[name] = cur
+			lastKey = ""
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok || cur == nil {
+			continue
+		}
+		if i := strings.Index(val, " ;"); i != -1 {
+			val = val[:i]
+		}
+		lastKey = strings.TrimSpace(key)
+		cur.Values[lastKey] = strings.TrimSpace(val)
+	}
+	return sections
+}
+
+// supervisordDetails adds the [program:x] stanza that runs the target to
+// a supervisord source. supervisord tells its children which program and
+// group they belong to through SUPERVISOR_PROCESS_NAME/GROUP_NAME.
+func supervisordDetails(src *Source, ancestry []Process, i int) {
+	var process, group string
+	for j := len(ancestry) - 1; j > i && process == ""; j-- {
+		process = envValue(ancestry[j], "SUPERVISOR_PROCESS_NAME")
+		group = envValue(ancestry[j], "SUPERVISOR_GROUP_NAME")
+	}
+	details := make(map[string]string)
+	config := supervisordConfigPath(ancestry[i])
+	if config != "" {
+		details["config_file"] = config
+	}
+	if process == "" {
+		src.Details = details
+		return
+	}
+	details["supervisor_program"] = process
+	if group != "" && group != process {
+		details["supervisor_group"] = group
+	}
+	src.Evidence = append(src.Evidence, "environment sets SUPERVISOR_PROCESS_NAME="+process)
+	src.Description = "supervisord program " + process
+
+	// Programs in a [group:x] keep their own section; numprocs copies
+	// (web_00, web_01) share their group's
+	sections := loadSupervisordConfig(config)
+	name := "program:" + process
+	sec := sections[name]
+	if sec == nil && group != "" {
+		name = "program:" + group
+		sec = sections[name]
+	}
+	if sec == nil {
+		src.Details = details
+		return
+	}
+	details["config_file"] = sec.File
+	details["config_section"] = "[" + name + "]"
+	details["autorestart"] = "unexpected"
+	for _, key := range []string{"command", "autorestart", "directory", "user"} {
+		if v := sec.Values[key]; v != "" {
+			details[key] = v
+		}
+	}
+	src.Description += ", defined in " + details["config_section"] + " of " + sec.File
+	src.Details = details
+}
@@ -0,0 +1,337 @@
+//go:build linux
+
+package detect
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Unit search paths in systemd's precedence order.
+var systemUnitDirs = []string{
+	"/etc/systemd/system",
+	"/run/systemd/transient",
+	"/run/systemd/system",
+	"/run/systemd/generator",
+	"/usr/local/lib/systemd/system",
+	"/usr/lib/systemd/system",
+	"/lib/systemd/system",
+}
+
+// userUnitDirs returns the search paths for a user manager instance, in
+// the precedence order systemd.unit(5) documents, taking the XDG
+// directories at their defaults. Units installed by packages or pipx
+// under ~/.local/share are found as well as those in ~/.config.
+func userUnitDirs(uid string) []string {
+	run := "/run/user/" + uid + "/systemd"
+	_, home := lookupUser(uid)
+	inHome := func(dir string) []string {
+		if home == "" {
+			return nil
+		}
+		return []string{home + dir}
+	}
+	var dirs []string
+	dirs = append(dirs, inHome("/.config/systemd/user.control")...)
+	dirs = append(dirs, run+"/user.control", run+"/transient", run+"/generator.early")
+	dirs = append(dirs, inHome("/.config/systemd/user")...)
+	dirs = append(dirs, "/etc/xdg/systemd/user", "/etc/systemd/user", run+"/user", "/run/systemd/user", run+"/generator")
+	dirs = append(dirs, inHome("/.local/share/systemd/user")...)
+	return append(dirs,
+		"/usr/local/share/systemd/user",
+		"/usr/share/systemd/user",
+		"/usr/local/lib/systemd/user",
+		"/usr/lib/systemd/user",
+		run+"/generator.late",
+	)
+}
+
+// unitFile is a parsed unit with its drop-ins applied.
+type unitFile struct {
+	Path     string
+	DropIns  []string
+	Masked   bool
+	sections map[string]map[string][]string
+}
+
+// get returns the effective (last) value of a key.
+func (u *unitFile) get(section, key string) string {
+	vals := u.sections[section][key]
+	if len(vals) == 0 {
+		return ""
+	}
+	return vals[len(vals)-1]
+}
+
+// all returns every value of a list-type key.
+func (u *unitFile) all(section, key string) []string {
+	return u.sections[section][key]
+}
+
+// unitNames returns name plus its template (foo@bar.service → foo@.service).
+func unitNames(name string) []string {
+	names := []string{name}
+	if at := strings.Index(name, "@"); at != -1 {
+		if dot := strings.LastIndex(name, "."); dot > at+1 {
+			names = append(names, name[:at+1]+name[dot:])
+		}
+	}
+	return names
+}
+
+// loadUnit finds a unit file and its drop-ins in dirs. Template instances
+// fall back to the template's file.
+func loadUnit(name string, dirs []string) *unitFile {
+	names := unitNames(name)
+	u := &unitFile{sections: make(map[string]map[string][]string)}
+find:
+	for _, n := range names {
+		for _, dir := range dirs {
+			path := dir + "/" + n
+			if target, err := os.Readlink(path); err == nil && target == "/dev/null" {
+				u.Path, u.Masked = path, true
+				return u
+			}
+			if _, err := os.Stat(path); err == nil {
+				u.Path = path
+				break find
+			}
+		}
+	}
+	if u.Path == "" {
+		return nil
+	}
+	parseUnitFile(u.Path, u.sections)
+
+	// Drop-ins override by file name, applied in lexical order
+	dropins := make(map[string]string)
+	for i := len(names) - 1; i >= 0; i-- {
+		for j := len(dirs) - 1; j >= 0; j-- {
+			matches, _ := filepath.Glob(dirs[j] + "/" + names[i] + ".d/*.conf")
+			for _, m := range matches {
+				dropins[filepath.Base(m)] = m
+			}
+		}
+	}
+	var keys []string
+	for k := range dropins {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		u.DropIns = append(u.DropIns, dropins[k])
+		parseUnitFile(dropins[k], u.sections)
+	}
+	return u
+}
+
+// parseUnitFile merges an INI-style unit file into sections. An empty
+// assignment resets list-type keys, as systemd does.
+func parseUnitFile(path string, sections map[string]map[string][]string) {
+	f, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	var section, pending string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, "\\") {
+			pending += strings.TrimSuffix(line, "\\") + " "
+			continue
+		}
+		line, pending = pending+line, ""
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			section = line[1 : len(line)-1]
+			if sections[section] == nil {
+				sections[section] = make(map[string][]string)
+			}
+			continue
+		}
+		key, val, ok := strings.Cut(line, "=")
+		if !ok || section == "" {
+			continue
+		}
+		key, val = strings.TrimSpace(key), strings.TrimSpace(val)
+		if val == "" {
+			delete(sections[section], key)
+			continue
+		}
+		sections[section][key] = append(sections[section][key], val)
+	}
+}
+
+// unitEnablement reports whether a unit is pulled in at boot through
+// *.wants/ or *.requires/ symlinks. Links an administrator made under
+// /etc (or a user's config) count as enabled; links shipped by the
+// package under /usr/lib or /lib are reported as vendor-enabled, and
+// links under /run as enabled-runtime, since they vanish on reboot.
+func unitEnablement(name string, u *unitFile, dirs []string) string {
+	if u.Masked {
+		return "masked"
+	}
+	names := unitNames(name)
+	targets := make(map[string][]string)
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		state := "enabled"
+		switch {
+		case strings.Contains(dir, "/lib/systemd/") || strings.Contains(dir, "/share/systemd/"):
+			state = "vendor-enabled"
+		case strings.HasPrefix(dir, "/run/"):
+			state = "enabled-runtime"
+		}
+		for _, n := range names {
+			for _, kind := range []string{".wants", ".requires"} {
+				matches, _ := filepath.Glob(dir + "/*" + kind + "/" + n)
+				for _, m := range matches {
+					t := strings.TrimSuffix(filepath.Base(filepath.Dir(m)), kind)
+					if !seen[state+t] {
+						seen[state+t] = true
+						targets[state] = append(targets[state], t)
+					}
+				}
+			}
+		}
+	}
+	for _, state := range []string{"enabled", "enabled-runtime", "vendor-enabled"} {
+		if len(targets[state]) > 0 {
+			return state + " (" + strings.Join(targets[state], ", ") + ")"
+		}
+	}
+	if len(u.sections["Install"]) == 0 {
+		return "static"
+	}
+	return "disabled"
+}
+
+// expandSpecifiers resolves the unit-name specifiers systemd substitutes
+// in unit files, so foo@bar.service shows ExecStart with bar rather than
+// %i. Specifiers that need the manager's runtime state are left as-is.
+func expandSpecifiers(s, name string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	base := strings.TrimSuffix(name, filepath.Ext(name))
+	prefix, instance, _ := strings.Cut(base, "@")
+	unescape := func(v string) string { return unescapeUnit(strings.ReplaceAll(v, "-", "/")) }
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			b.WriteString(name)
+		case 'N':
+			b.WriteString(base)
+		case 'p':
+			b.WriteString(prefix)
+		case 'P':
+			b.WriteString(unescape(prefix))
+		case 'i':
+			b.WriteString(instance)
+		case 'I':
+			b.WriteString(unescape(instance))
+		case 'f':
+			if instance != "" {
+				b.WriteString("/" + strings.TrimPrefix(unescape(instance), "/"))
+			} else {
+				b.WriteString("/" + strings.TrimPrefix(unescape(prefix), "/"))
+			}
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// unitDetails extracts the fields that explain how a unit runs its process.
+func unitDetails(name, manager, uid string, details map[string]string) {
+	dirs := systemUnitDirs
+	if manager != "system" {
+		dirs = userUnitDirs(uid)
+	}
+	u := loadUnit(name, dirs)
+	if u == nil {
+		return
+	}
+	details["unit_file"] = u.Path
+	if len(u.DropIns) > 0 {
+		details["drop_ins"] = strings.Join(u.DropIns, ", ")
+	}
+	details["enabled"] = unitEnablement(name, u, dirs)
+	if u.Masked || !strings.HasSuffix(name, ".service") {
+		return
+	}
+
+	restart := u.get("Service", "Restart")
+	if restart == "" {
+		restart = "no"
+	}
+	details["restart"] = restart
+	for key, field := range map[string]string{
+		"exec_start":  "ExecStart",
+		"user":        "User",
+		"working_dir": "WorkingDirectory",
+	} {
+		if v := u.get("Service", field); v != "" {
+			details[key] = expandSpecifiers(v, name)
+		}
+	}
+	if v := u.all("Service", "EnvironmentFile"); len(v) > 0 {
+		details["environment_file"] = expandSpecifiers(strings.Join(v, ", "), name)
+	}
+	if v := u.all("Install", "WantedBy"); len(v) > 0 {
+		details["wanted_by"] = strings.Join(v, " ")
+	}
+	if v := u.get("Service", "Type"); v != "" {
+		details["service_type"] = v
+	}
+	if socket, sock := unitSocket(name, dirs); sock != nil {
+		details["socket"] = socket
+		if listens := socketListens(sock); len(listens) > 0 {
+			details["socket_listen"] = strings.Join(listens, ", ")
+		}
+	}
+	if timer, t := unitTimer(name, dirs); t != nil {
+		details["timer"] = timer
+		details["timer_file"] = t.Path
+		if sched := timerSchedule(t); sched != "" {
+			details["timer_schedule"] = sched
+		}
+	}
+}
+
+// quadletSource returns the .container/.kube/.pod file a quadlet-generated
+// unit was built from, or "" for ordinary units.
+func quadletSource(unit, uid string) string {
+	dirs := systemUnitDirs
+	if uid != "" {
+		dirs = userUnitDirs(uid)
+	}
+	u := loadUnit(unit, dirs)
+	if u == nil || !strings.Contains(u.Path, "/generator") {
+		return ""
+	}
+	src := u.get("Unit", "SourcePath")
+	for _, ext := range []string{".container", ".kube", ".pod"} {
+		if strings.HasSuffix(src, ext) {
+			return src
+		}
+	}
+	return ""
+}
@@ -0,0 +1,116 @@
+//go:build linux
+
+package detect
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// unitTimer finds the .timer unit that activates service: the timer of
+// the same name (including template instances) unless it points
+// elsewhere, or any timer whose Unit= names the service.
+func unitTimer(service string, dirs []string) (name string, timer *unitFile) {
+	name = strings.TrimSuffix(service, ".service") + ".timer"
+	if t := loadUnit(name, dirs); t != nil && !t.Masked {
+		if target := t.get("Timer", "Unit"); target == "" || target == service {
+			return name, t
+		}
+	}
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(dir + "/*.timer")
+		for _, m := range matches {
+			n := filepath.Base(m)
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+			if t := loadUnit(n, dirs); t != nil && !t.Masked && t.get("Timer", "Unit") == service {
+				return n, t
+			}
+		}
+	}
+	return "", nil
+}
+
+// timerSchedule describes when a timer fires, e.g. "daily at 03:00" or
+// "15min after boot; every 1h after activation".
+func timerSchedule(t *unitFile) string {
+	var parts []string
+	for _, cal := range t.all("Timer", "OnCalendar") {
+		parts = append(parts, describeCalendar(cal))
+	}
+	for _, m := range monotonicTimers {
+		for _, v := range t.all("Timer", m[0]) {
+			parts = append(parts, strings.Replace(m[1], "%s", v, 1))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// monotonicTimers phrases the relative timer settings, in display order.
+var monotonicTimers = [][2]string{
+	{"OnBootSec", "%s after boot"},
+	{"OnStartupSec", "%s after the manager starts"},
+	{"OnActiveSec", "%s after the timer starts"},
+	{"OnUnitActiveSec", "every %s after activation"},
+	{"OnUnitInactiveSec", "%s after the service stops"},
+}
+
+var calendarShorthands = map[string]string{
+	"minutely":     "every minute",
+	"hourly":       "hourly",
+	"daily":        "daily at 00:00",
+	"weekly":       "weekly on Mon at 00:00",
+	"monthly":      "monthly on the 1st at 00:00",
+	"yearly":       "yearly on Jan 1 at 00:00",
+	"annually":     "yearly on Jan 1 at 00:00",
+	"quarterly":    "quarterly at 00:00",
+	"semiannually": "twice a year at 00:00",
+}
+
+// describeCalendar turns a common OnCalendar= expression into words and
+// returns anything more intricate as written.
+//
+//	*-*-* 03:00:00     → daily at 03:00
+//	Mon..Fri 09:30     → Mon..Fri at 09:30
+//	*-*-01 04:00       → monthly on day 01 at 04:00
+func describeCalendar(expr string) string {
+	if s, ok := calendarShorthands[strings.ToLower(expr)]; ok {
+		return s
+	}
+	var dow, date, clock string
+	for _, f := range strings.Fields(expr) {
+		switch {
+		case strings.Contains(f, ":"):
+			clock = f
+		case strings.Contains(f, "-"):
+			date = f
+		case dow == "" && date == "" && clock == "":
+			dow = f
+		default:
+			return expr
+		}
+	}
+	if clock == "" || strings.ContainsAny(clock, "*/,~") {
+		return expr
+	}
+	if c := strings.Split(clock, ":"); len(c) == 3 && c[2] == "00" {
+		clock = c[0] + ":" + c[1]
+	}
+
+	var when string
+	switch {
+	case date == "" || date == "*-*-*":
+		when = "daily"
+		if dow != "" {
+			when = dow
+		}
+	case strings.HasPrefix(date, "*-*-") && dow == "":
+		when = "monthly on day " + strings.TrimPrefix(date, "*-*-")
+	default:
+		return expr
+	}
+	return when + " at " + clock
+}
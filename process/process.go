@@ -33,6 +33,7 @@ func (p Process) GetHealth() string        { return p.Health }
 func (p Process) GetContainer() string     { return p.Container }
 func (p Process) GetService() string       { return p.Service }
 func (p Process) GetStartedAt() time.Time  { return p.StartedAt }
+func (p Process) GetEnv() []string         { return p.Env }
 
 // BuildAncestry walks the process tree from pid up to init (PID 1).
 // Returns the chain from root to target: [init, ..., parent, target]
@@ -11,6 +11,8 @@ import (
 	"strings"
 	"syscall"
 	"time"
+
+	"github.com/pranshuparmar/witr/detect"
 )
 
 // Read reads process info from /proc filesystem.
@@ -64,7 +66,8 @@ func Read(pid int) (Process, error) {
 		WorkingDir:     readCwd(pid),
 		GitRepo:        readGitRepo(pid),
 		GitBranch:      readGitBranch(pid),
-		Container:      detectContainer(pid),
+		Container:      detect.CgroupContainer(pid),
+		Service:        detect.CgroupUnit(pid),
 		ListeningPorts: readPorts(pid),
 		BindAddresses:  readBindAddrs(pid),
 		Health:         health,
@@ -135,23 +138,6 @@ func readUser(pid int) string {
 	return uidStr
 }
 
-func detectContainer(pid int) string {
-	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/cgroup", pid))
-	if err != nil {
-		return ""
-	}
-	s := string(data)
-	switch {
-	case strings.Contains(s, "docker"):
-		return "docker"
-	case strings.Contains(s, "containerd"):
-		return "containerd"
-	case strings.Contains(s, "kubepods"):
-		return "kubernetes"
-	}
-	return ""
-}
-
 func readGitRepo(pid int) string {
 	cwd := readCwd(pid)
 	for dir := cwd; dir != "/" && dir != ""; dir = parentDir(dir) {